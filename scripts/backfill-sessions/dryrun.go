@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/howiewang/personal-blog/scripts/claug"
+)
+
+// dryRunFormats are the accepted --dry-run-format values.
+var dryRunFormats = map[string]func(io.Writer, []claug.SessionMetrics, map[string][]string) error{
+	"jsonl": writePayloadsJSONL,
+	"csv":   writeSummaryCSV,
+}
+
+// dryRunFormat picks the output format: explicit, else from the file
+// extension, else JSON Lines.
+func dryRunFormat(format, path string) (string, error) {
+	if format == "" {
+		format = "jsonl"
+		if strings.EqualFold(filepath.Ext(path), ".csv") {
+			format = "csv"
+		}
+	}
+	if _, ok := dryRunFormats[format]; !ok {
+		return "", fmt.Errorf("--dry-run-format: unknown format %q (want jsonl or csv)", format)
+	}
+	return format, nil
+}
+
+// writeDryRun writes sessions to path ("-" for stdout) in format.
+func writeDryRun(path, format string, sessions []claug.SessionMetrics, decisions map[string][]string) error {
+	if path == "-" {
+		return dryRunFormats[format](os.Stdout, sessions, decisions)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating %s: %w", path, err)
+	}
+	if err := dryRunFormats[format](f, sessions, decisions); err != nil {
+		f.Close()
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+// writePayloadsJSONL writes each session exactly as it would appear in a
+// heartbeat payload, one per line.
+func writePayloadsJSONL(w io.Writer, sessions []claug.SessionMetrics, _ map[string][]string) error {
+	enc := json.NewEncoder(w)
+	for _, s := range sessions {
+		if err := enc.Encode(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeSummaryCSV writes one row of metrics per session, plus the privacy
+// policy's decisions joined with "; ".
+func writeSummaryCSV(w io.Writer, sessions []claug.SessionMetrics, decisions map[string][]string) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{
+		"session_id", "created_at", "project", "model", "privacy_level", "provider_version",
+		"total_tokens", "input_tokens", "cache_read_input_tokens", "output_tokens",
+		"tool_calls", "user_prompts", "active_time_seconds", "has_summary", "policy",
+	})
+	for _, s := range sessions {
+		created := ""
+		if s.CreatedAt != 0 {
+			created = formatUnix(s.CreatedAt)
+		}
+		_ = cw.Write([]string{
+			s.SessionID, created, s.Project, s.Model, s.PrivacyLevel, s.ProviderVersion,
+			strconv.FormatInt(s.TotalTokens, 10),
+			strconv.FormatInt(s.InputTokens, 10),
+			strconv.FormatInt(s.CacheReadInputTokens, 10),
+			strconv.FormatInt(s.OutputTokens, 10),
+			strconv.Itoa(s.ToolCalls),
+			strconv.Itoa(s.UserPrompts),
+			strconv.Itoa(s.ActiveTime),
+			strconv.FormatBool(s.Summary != ""),
+			strings.Join(decisions[s.SessionID], "; "),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// backfillTotals aggregates sessions the way build-sessions does for the
+// site: every session counts, but only fully public sessions contribute to
+// the top tools. Costs are left out; build-sessions adds those from its
+// pricing table.
+func backfillTotals(sessions []claug.SessionMetrics) claug.Totals {
+	var all claug.Usage
+	byModel := claug.ModelUsage{}
+	var toolCounts []map[string]int
+	for _, s := range sessions {
+		all.AddMetrics(s)
+		byModel.For(s.Model).AddMetrics(s)
+		if s.PrivacyLevel == "full" && len(s.ToolCounts) > 0 {
+			toolCounts = append(toolCounts, s.ToolCounts)
+		}
+	}
+
+	totals := all.Totals()
+	totals.TopTools = claug.TopTools(toolCounts, 5)
+	totals.ByModel = byModel.Rows()
+	return totals
+}
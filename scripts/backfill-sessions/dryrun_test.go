@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+
+	"github.com/howiewang/personal-blog/scripts/claug"
+)
+
+func dryRunSessions() []claug.SessionMetrics {
+	return []claug.SessionMetrics{
+		{SessionID: "a", Model: "opus", TotalTokens: 1000, InputTokens: 100, OutputTokens: 900, ToolCalls: 3,
+			ToolCounts: map[string]int{"Read": 2, "Bash": 1}, ActiveTime: 60, PrivacyLevel: "full", Summary: "x", CreatedAt: 1770000000},
+		{SessionID: "b", Model: "", TotalTokens: 500, ToolCalls: 9,
+			ToolCounts: map[string]int{"mcp__secret__tool": 9}, ActiveTime: 30, PrivacyLevel: "metrics_only"},
+		{SessionID: "c", Model: "opus", TotalTokens: 2000, ToolCounts: map[string]int{"Read": 1}, PrivacyLevel: "full"},
+	}
+}
+
+func TestWritePayloadsJSONL(t *testing.T) {
+	var buf bytes.Buffer
+	sessions := dryRunSessions()
+	if err := writePayloadsJSONL(&buf, sessions, nil); err != nil {
+		t.Fatal(err)
+	}
+	sc := bufio.NewScanner(&buf)
+	var n int
+	for sc.Scan() {
+		var got claug.SessionMetrics
+		if err := json.Unmarshal(sc.Bytes(), &got); err != nil {
+			t.Fatalf("line %d: %v", n, err)
+		}
+		if got.SessionID != sessions[n].SessionID || got.TotalTokens != sessions[n].TotalTokens {
+			t.Errorf("line %d = %+v", n, got)
+		}
+		n++
+	}
+	if n != len(sessions) {
+		t.Errorf("wrote %d lines, want %d", n, len(sessions))
+	}
+}
+
+func TestWriteSummaryCSV(t *testing.T) {
+	var buf bytes.Buffer
+	decisions := map[string][]string{"b": {"privacy full -> metrics_only (floor)", "project x -> project-1"}}
+	if err := writeSummaryCSV(&buf, dryRunSessions(), decisions); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 4 || rows[0][0] != "session_id" {
+		t.Fatalf("rows = %v", rows)
+	}
+	if rows[1][1] != "2026-02-02T02:40:00Z" || rows[1][13] != "true" || rows[2][1] != "" {
+		t.Errorf("rows = %v", rows[1:3])
+	}
+	if rows[2][14] != "privacy full -> metrics_only (floor); project x -> project-1" {
+		t.Errorf("policy column = %q", rows[2][14])
+	}
+}
+
+func TestBackfillTotals(t *testing.T) {
+	totals := backfillTotals(dryRunSessions())
+	if totals.SessionCount != 3 || totals.TotalTokens != 3500 || totals.TotalToolCalls != 12 {
+		t.Errorf("totals = %+v", totals)
+	}
+	if totals.TotalTokensDisplayShort != "3.5k" || totals.TotalActiveTimeDisplay != "1m 30s" {
+		t.Errorf("displays = %q %q", totals.TotalTokensDisplayShort, totals.TotalActiveTimeDisplay)
+	}
+	// Tools of non-public sessions stay out of the top tools, as on the site.
+	if len(totals.TopTools) != 2 || totals.TopTools[0].Name != "Read" || totals.TopTools[0].Count != 3 {
+		t.Errorf("TopTools = %+v", totals.TopTools)
+	}
+	if len(totals.ByModel) != 2 || totals.ByModel[0].Model != "opus" || totals.ByModel[1].Model != claug.UnknownModel {
+		t.Errorf("ByModel = %+v", totals.ByModel)
+	}
+	data, _ := json.Marshal(totals)
+	if bytes.Contains(data, []byte("estimated_cost")) {
+		t.Errorf("totals without pricing carry cost fields: %s", data)
+	}
+}
+
+func TestDryRunFormat(t *testing.T) {
+	tests := []struct{ format, path, want string }{
+		{"", "out.csv", "csv"},
+		{"", "out.CSV", "csv"},
+		{"", "out.jsonl", "jsonl"},
+		{"", "-", "jsonl"},
+		{"jsonl", "out.csv", "jsonl"},
+	}
+	for _, tt := range tests {
+		if got, err := dryRunFormat(tt.format, tt.path); err != nil || got != tt.want {
+			t.Errorf("dryRunFormat(%q, %q) = %q, %v; want %q", tt.format, tt.path, got, err, tt.want)
+		}
+	}
+	if _, err := dryRunFormat("xml", ""); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/howiewang/personal-blog/scripts/claug"
+)
+
+// sessionFilter narrows a backfill (or verify) to part of the cc-live
+// history, so specific gaps can be repaired without resending everything.
+type sessionFilter struct {
+	From       time.Time
+	To         time.Time
+	Projects   []string        // globs against the raw project or its claug.ProjectName
+	SessionIDs map[string]bool // nil means all
+}
+
+// filterFlags holds the raw flag values until they are parsed.
+type filterFlags struct {
+	from, to   string
+	projects   claug.StringList
+	sessionIDs string
+}
+
+func registerFilterFlags(fs *flag.FlagSet) *filterFlags {
+	f := &filterFlags{}
+	fs.StringVar(&f.from, "from", "", "only sessions created on or after this date (YYYY-MM-DD or RFC3339)")
+	fs.StringVar(&f.to, "to", "", "only sessions created before this date (YYYY-MM-DD or RFC3339)")
+	fs.Var(&f.projects, "project", "only projects matching this glob; repeatable")
+	fs.StringVar(&f.sessionIDs, "session-ids", "", "only the session IDs listed in this file, one per line (- reads stdin)")
+	return f
+}
+
+func (f *filterFlags) parse(stdin io.Reader) (sessionFilter, error) {
+	from, err := claug.ParseDate(f.from)
+	if err != nil {
+		return sessionFilter{}, fmt.Errorf("--from: %w", err)
+	}
+	to, err := claug.ParseDate(f.to)
+	if err != nil {
+		return sessionFilter{}, fmt.Errorf("--to: %w", err)
+	}
+	if !from.IsZero() && !to.IsZero() && !to.After(from) {
+		return sessionFilter{}, fmt.Errorf("--to (%s) must be after --from (%s)", f.to, f.from)
+	}
+	for _, pattern := range f.projects {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return sessionFilter{}, fmt.Errorf("bad project glob %q: %w", pattern, err)
+		}
+	}
+
+	filter := sessionFilter{From: from, To: to, Projects: f.projects}
+	switch f.sessionIDs {
+	case "":
+	case "-":
+		if filter.SessionIDs, err = readSessionIDs(stdin); err != nil {
+			return sessionFilter{}, fmt.Errorf("--session-ids: reading stdin: %w", err)
+		}
+	default:
+		file, err := os.Open(f.sessionIDs)
+		if err != nil {
+			return sessionFilter{}, fmt.Errorf("--session-ids: %w", err)
+		}
+		defer file.Close()
+		if filter.SessionIDs, err = readSessionIDs(file); err != nil {
+			return sessionFilter{}, fmt.Errorf("--session-ids: reading %s: %w", f.sessionIDs, err)
+		}
+	}
+	return filter, nil
+}
+
+// readSessionIDs reads whitespace- or comma-separated IDs, ignoring blank
+// lines and # comments.
+func readSessionIDs(r io.Reader) (map[string]bool, error) {
+	ids := make(map[string]bool)
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		for _, id := range strings.FieldsFunc(line, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+			ids[id] = true
+		}
+	}
+	return ids, sc.Err()
+}
+
+func (f sessionFilter) active() bool {
+	return !f.From.IsZero() || !f.To.IsZero() || len(f.Projects) > 0 || f.SessionIDs != nil
+}
+
+// match reports whether a session passes every filter. Undated sessions never
+// match a date window (see claug.InWindow).
+func (f sessionFilter) match(sessionID, project string, createdAt int64) bool {
+	if f.SessionIDs != nil && !f.SessionIDs[sessionID] {
+		return false
+	}
+	if !claug.InWindow(f.From, f.To, createdAt) {
+		return false
+	}
+	if len(f.Projects) > 0 && !claug.MatchProject(f.Projects, project) {
+		return false
+	}
+	return true
+}
+
+func (f sessionFilter) apply(sessions []claug.SessionMetrics) []claug.SessionMetrics {
+	if !f.active() {
+		return sessions
+	}
+	var kept []claug.SessionMetrics
+	for _, s := range sessions {
+		if f.match(s.SessionID, s.Project, s.CreatedAt) {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
+
+// query returns the date window as /api/sessions parameters, so verify only
+// downloads the part of the account it compares.
+func (f sessionFilter) query() url.Values {
+	q := url.Values{}
+	if !f.From.IsZero() {
+		q.Set("from", f.From.UTC().Format(time.RFC3339))
+	}
+	if !f.To.IsZero() {
+		q.Set("to", f.To.UTC().Format(time.RFC3339))
+	}
+	return q
+}
+
+// applyRemote narrows claug's sessions to the ones verify should compare
+// against local, the filtered cc-live sessions after the privacy policy. The
+// project globs can't be rerun on claug's projects, which --hash-projects may
+// have rewritten, so under --project only sessions in local are kept; the
+// date window and session IDs are rechecked as usual.
+func (f sessionFilter) applyRemote(sessions []claug.SessionStats, local []claug.SessionMetrics) []claug.SessionStats {
+	if !f.active() {
+		return sessions
+	}
+	var localIDs map[string]bool
+	if len(f.Projects) > 0 {
+		localIDs = make(map[string]bool, len(local))
+		for _, s := range local {
+			localIDs[s.SessionID] = true
+		}
+	}
+	dates := sessionFilter{From: f.From, To: f.To, SessionIDs: f.SessionIDs}
+	var kept []claug.SessionStats
+	for _, s := range sessions {
+		if localIDs != nil && !localIDs[s.SessionID] {
+			continue
+		}
+		if dates.match(s.SessionID, s.Project, s.CreatedAt) {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
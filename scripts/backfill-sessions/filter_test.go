@@ -0,0 +1,112 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/howiewang/personal-blog/scripts/claug"
+)
+
+func TestFilterFlagsParseSessionIDs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ids.txt")
+	if err := os.WriteFile(path, []byte("# gaps from verify\ns01\ns02, s03\n\n  s04 # trailing comment\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := (&filterFlags{sessionIDs: path}).parse(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.SessionIDs) != 4 || !f.SessionIDs["s03"] || !f.SessionIDs["s04"] {
+		t.Errorf("SessionIDs = %v, want s01-s04", f.SessionIDs)
+	}
+
+	f, err = (&filterFlags{sessionIDs: "-"}).parse(strings.NewReader("a\nb\n"))
+	if err != nil || len(f.SessionIDs) != 2 {
+		t.Errorf("stdin SessionIDs = %v, %v", f.SessionIDs, err)
+	}
+
+	// An empty file selects nothing rather than everything.
+	empty := filepath.Join(t.TempDir(), "empty.txt")
+	_ = os.WriteFile(empty, nil, 0o644)
+	if f, err := (&filterFlags{sessionIDs: empty}).parse(nil); err != nil || f.SessionIDs == nil || !f.active() {
+		t.Errorf("empty ID file: %+v, %v", f, err)
+	}
+
+	for _, bad := range []filterFlags{
+		{sessionIDs: filepath.Join(t.TempDir(), "missing.txt")},
+		{from: "2026-03-01", to: "2026-03-01"},
+		{to: "soon"},
+		{projects: claug.StringList{"[oops"}},
+	} {
+		if _, err := bad.parse(nil); err == nil {
+			t.Errorf("parse(%+v) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestFilterApply(t *testing.T) {
+	day := func(d int) int64 { return time.Date(2026, 2, d, 12, 0, 0, 0, time.UTC).Unix() }
+	sessions := []claug.SessionMetrics{
+		{SessionID: "a", Project: "/Users/howie/code/personal-blog", CreatedAt: day(1)},
+		{SessionID: "b", Project: "claug", CreatedAt: day(5)},
+		{SessionID: "c", Project: `C:\src\claug\`, CreatedAt: day(10)},
+		{SessionID: "d", Project: "claug"}, // undated
+	}
+	tests := []struct {
+		name   string
+		filter sessionFilter
+		want   string
+	}{
+		{"no filter", sessionFilter{}, "a,b,c,d"},
+		{"from", sessionFilter{From: time.Date(2026, 2, 5, 0, 0, 0, 0, time.UTC)}, "b,c"},
+		{"to is exclusive", sessionFilter{To: time.Unix(day(5), 0)}, "a"},
+		{"project base name", sessionFilter{Projects: []string{"claug"}}, "b,c,d"},
+		{"project path glob", sessionFilter{Projects: []string{"/Users/*/code/*"}}, "a"},
+		{"ids", sessionFilter{SessionIDs: map[string]bool{"a": true, "d": true}}, "a,d"},
+		{"combined", sessionFilter{Projects: []string{"clau?"}, SessionIDs: map[string]bool{"a": true, "c": true}}, "c"},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, s := range tt.filter.apply(sessions) {
+			got = append(got, s.SessionID)
+		}
+		if strings.Join(got, ",") != tt.want {
+			t.Errorf("%s: got %v, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestFilterApplyRemote checks verify keeps claug's copies of the filtered
+// sessions even when --hash-projects rewrote their projects.
+func TestFilterApplyRemote(t *testing.T) {
+	day := func(d int) int64 { return time.Date(2026, 2, d, 12, 0, 0, 0, time.UTC).Unix() }
+	filter := sessionFilter{From: time.Date(2026, 2, 2, 0, 0, 0, 0, time.UTC), Projects: []string{"claug"}}
+	policy := mustPolicy(t, policyFlags{hashProjects: true, hashSalt: "pepper"})
+	local, _ := applyPolicy(filter.apply([]claug.SessionMetrics{
+		{SessionID: "a", Project: "/Users/howie/code/claug", CreatedAt: day(5)},
+		{SessionID: "b", Project: "personal-blog", CreatedAt: day(5)},
+		{SessionID: "c", Project: "claug", CreatedAt: day(1)},
+	}), policy)
+	remote := []claug.SessionStats{
+		{SessionID: "a", Project: local[0].Project, CreatedAt: day(5)},
+		{SessionID: "b", Project: "project-0123", CreatedAt: day(5)},
+		{SessionID: "c", Project: "project-4567", CreatedAt: day(1)},
+	}
+	var got []string
+	for _, s := range filter.applyRemote(remote, local) {
+		got = append(got, s.SessionID)
+	}
+	if strings.Join(got, ",") != "a" {
+		t.Errorf("applyRemote kept %v, want [a]", got)
+	}
+	if r := reconcile(local, filter.applyRemote(remote, local)); !r.ok(false) {
+		t.Errorf("reconcile = %+v, want ok", r)
+	}
+
+	if got := filter.query().Encode(); got != "from=2026-02-02T00%3A00%3A00Z" {
+		t.Errorf("query = %s", got)
+	}
+}
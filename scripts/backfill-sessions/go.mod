@@ -2,7 +2,10 @@ module github.com/howiewang/personal-blog/scripts/backfill-sessions
 
 go 1.25.0
 
-require modernc.org/sqlite v1.47.0
+require (
+	github.com/howiewang/personal-blog/scripts/claug v0.0.0
+	modernc.org/sqlite v1.47.0
+)
 
 require (
 	github.com/dustin/go-humanize v1.0.1 // indirect
@@ -11,7 +14,10 @@ require (
 	github.com/ncruces/go-strftime v1.0.0 // indirect
 	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
 	golang.org/x/sys v0.42.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
 	modernc.org/libc v1.70.0 // indirect
 	modernc.org/mathutil v1.7.1 // indirect
 	modernc.org/memory v1.11.0 // indirect
 )
+
+replace github.com/howiewang/personal-blog/scripts/claug => ../claug
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+const (
+	ledgerAcked  = "acked"
+	ledgerFailed = "failed"
+)
+
+// ledger records, per claug endpoint, which sessions the heartbeat endpoint
+// has acknowledged so an interrupted or partially failed backfill can resume
+// without resending everything. It lives in its own SQLite file so the
+// cc-live database is only ever read.
+type ledger struct {
+	db       *sql.DB
+	endpoint string
+}
+
+// ledgerEntry is one session's delivery state.
+type ledgerEntry struct {
+	SessionID string
+	Status    string
+	Attempts  int
+	LastError string
+	UpdatedAt time.Time
+}
+
+func openLedger(path, endpoint string) (*ledger, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("opening ledger %s: %w", path, err)
+	}
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS backfill_ledger (
+		endpoint   TEXT NOT NULL,
+		session_id TEXT NOT NULL,
+		status     TEXT NOT NULL,
+		attempts   INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT NOT NULL DEFAULT '',
+		updated_at INTEGER NOT NULL,
+		PRIMARY KEY (endpoint, session_id)
+	)`); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("creating ledger table in %s: %w", path, err)
+	}
+	return &ledger{db: db, endpoint: endpoint}, nil
+}
+
+func (l *ledger) Close() error {
+	return l.db.Close()
+}
+
+// acked returns the IDs of every session already acknowledged by the
+// ledger's endpoint.
+func (l *ledger) acked() (map[string]bool, error) {
+	rows, err := l.db.Query(`SELECT session_id FROM backfill_ledger
+		WHERE endpoint = ? AND status = ?`, l.endpoint, ledgerAcked)
+	if err != nil {
+		return nil, fmt.Errorf("reading ledger: %w", err)
+	}
+	defer rows.Close()
+
+	ids := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("reading ledger: %w", err)
+		}
+		ids[id] = true
+	}
+	return ids, rows.Err()
+}
+
+// record stores the outcome of one delivery attempt for ids. A nil sendErr
+// marks them acknowledged; otherwise they are marked failed unless an
+// earlier run already got them acknowledged.
+func (l *ledger) record(ids []string, sendErr error) error {
+	status, lastError := ledgerAcked, ""
+	if sendErr != nil {
+		status, lastError = ledgerFailed, sendErr.Error()
+	}
+
+	tx, err := l.db.Begin()
+	if err != nil {
+		return fmt.Errorf("recording batch: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT INTO backfill_ledger
+		(endpoint, session_id, status, attempts, last_error, updated_at)
+		VALUES (?, ?, ?, 1, ?, ?)
+		ON CONFLICT (endpoint, session_id) DO UPDATE SET
+			status     = CASE WHEN status = 'acked' THEN 'acked' ELSE excluded.status END,
+			attempts   = attempts + 1,
+			last_error = excluded.last_error,
+			updated_at = excluded.updated_at`)
+	if err != nil {
+		return fmt.Errorf("recording batch: %w", err)
+	}
+	defer stmt.Close()
+
+	now := time.Now().Unix()
+	for _, id := range ids {
+		if _, err := stmt.Exec(l.endpoint, id, status, lastError, now); err != nil {
+			return fmt.Errorf("recording %s: %w", id, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// entries returns the ledger rows for ids, skipping sessions never attempted.
+func (l *ledger) entries(ids []string) (map[string]ledgerEntry, error) {
+	want := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		want[id] = true
+	}
+
+	rows, err := l.db.Query(`SELECT session_id, status, attempts, last_error, updated_at
+		FROM backfill_ledger WHERE endpoint = ?`, l.endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("reading ledger: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make(map[string]ledgerEntry)
+	for rows.Next() {
+		var (
+			e         ledgerEntry
+			updatedAt int64
+		)
+		if err := rows.Scan(&e.SessionID, &e.Status, &e.Attempts, &e.LastError, &updatedAt); err != nil {
+			return nil, fmt.Errorf("reading ledger: %w", err)
+		}
+		if want[e.SessionID] {
+			e.UpdatedAt = time.Unix(updatedAt, 0)
+			entries[e.SessionID] = e
+		}
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/howiewang/personal-blog/scripts/claug"
+)
+
+func testLedger(t *testing.T, endpoint string) *ledger {
+	t.Helper()
+	lg, err := openLedger(filepath.Join(t.TempDir(), "backfill.db"), endpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { lg.Close() })
+	return lg
+}
+
+func testMetrics(n int) []claug.SessionMetrics {
+	sessions := make([]claug.SessionMetrics, n)
+	for i := range sessions {
+		sessions[i] = claug.SessionMetrics{SessionID: fmt.Sprintf("s%02d", i), TotalTokens: 100}
+	}
+	return sessions
+}
+
+func TestLedgerAckIsSticky(t *testing.T) {
+	lg := testLedger(t, "https://api.example")
+	if err := lg.record([]string{"a", "b"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := lg.record([]string{"b", "c"}, errors.New("boom")); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := lg.entries([]string{"a", "b", "c", "d"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries["b"].Status != ledgerAcked || entries["b"].Attempts != 2 {
+		t.Errorf("b = %+v, want acked after 2 attempts", entries["b"])
+	}
+	if entries["c"].Status != ledgerFailed || entries["c"].LastError != "boom" {
+		t.Errorf("c = %+v, want failed with boom", entries["c"])
+	}
+	if _, ok := entries["d"]; ok {
+		t.Error("never-attempted session has a ledger entry")
+	}
+}
+
+func TestLedgerIsPerEndpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backfill.db")
+	prod, err := openLedger(path, "https://prod.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prod.Close()
+	if err := prod.record([]string{"a"}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	staging, err := openLedger(path, "https://staging.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer staging.Close()
+	if acked, _ := staging.acked(); len(acked) != 0 {
+		t.Errorf("staging sees prod acks: %v", acked)
+	}
+}
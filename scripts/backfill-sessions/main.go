@@ -1,277 +1,260 @@
 // One-time migration script: reads historical sessions from the local cc-live
 // SQLite database and POSTs them as heartbeats to the claug API.
 //
-// Usage: go run . [--dry-run]
+// Usage:
+//
+//	go run . [--dry-run [--dry-run-out file] [--dry-run-format jsonl|csv]]
+//	         [--resume] [--ledger path]
+//	         [--batch-size n] [--concurrency n] [--rate req/s]
+//	         [--retry-budget n] [--max-attempts n] [filters] [policy]
+//	go run . verify [--ignore-extra] [filters] [policy]
+//
+// where filters are --from/--to dates, repeatable --project globs and
+// --session-ids (a file of IDs, or - for stdin). --privacy-floor,
+// --redact-pattern, --strip-summaries and --hash-projects (which needs a
+// secret --hash-salt) rewrite sessions before upload; a dry run lists every
+// change they make. Sessions that end up below full privacy never carry
+// their summary or last prompt.
+//
+// A dry run prints the upload's totals as JSON in the same shape as the
+// "totals" block of site/data/cc_sessions.json. --dry-run-out also writes
+// the exact heartbeat payloads (JSON Lines) or a CSV summary to a file.
+//
+// Every batch outcome is recorded in a ledger (~/.cc-live/backfill.db by
+// default). After a partial failure, rerun with --resume to send only the
+// sessions the API has not acknowledged yet. --retry-budget is shared by the
+// whole upload, not each batch, so raise it for long backfills. verify diffs cc-live against the
+// API field by field and exits non-zero if they disagree.
 //
 // Delete this script after successful backfill.
 package main
 
 import (
-	"bytes"
-	"database/sql"
 	"encoding/json"
+	"flag"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/howiewang/personal-blog/scripts/claug"
 	_ "modernc.org/sqlite"
 )
 
-type claugAuthConfig struct {
-	APIKey   string `json:"api_key"`
-	Endpoint string `json:"endpoint"`
-}
-
-type sessionMetrics struct {
-	SessionID            string         `json:"session_id"`
-	TotalTokens          int64          `json:"total_tokens"`
-	InputTokens          int64          `json:"input_tokens"`
-	CacheReadInputTokens int64          `json:"cache_read_input_tokens"`
-	OutputTokens         int64          `json:"output_tokens"`
-	ToolCalls            int            `json:"tool_calls"`
-	ToolCounts           map[string]int `json:"tool_counts,omitempty"`
-	UserPrompts          int            `json:"user_prompts"`
-	ActiveTime           int            `json:"active_time_seconds"`
-	LastPrompt           string         `json:"last_prompt,omitempty"`
-	Project              string         `json:"project"`
-	Model                string         `json:"model"`
-	Summary              string         `json:"summary,omitempty"`
-	PrivacyLevel         string         `json:"privacy_level"`
-}
-
-type heartbeatPayload struct {
-	Sessions []sessionMetrics `json:"sessions"`
-}
-
 func main() {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 
-	dryRun := false
-	for _, arg := range os.Args[1:] {
-		if arg == "--dry-run" {
-			dryRun = true
-		}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		log.Fatalf("getting home dir: %v", err)
 	}
+	ccLiveDir := filepath.Join(home, ".cc-live")
 
-	cfg := loadConfig()
-	sessions := readSQLiteSessions()
-
-	log.Printf("found %d sessions in SQLite", len(sessions))
-
-	if dryRun {
-		log.Printf("dry run — not sending to API")
-		for _, s := range sessions {
-			log.Printf("  %s: project=%s tokens=%d tools=%d", s.SessionID, s.Project, s.TotalTokens, s.ToolCalls)
-		}
+	if len(os.Args) > 1 && os.Args[1] == "verify" {
+		runVerify(ccLiveDir, os.Args[2:])
 		return
 	}
 
-	// Send in batches of 10
-	batchSize := 10
-	sent := 0
-	failed := 0
-	for i := 0; i < len(sessions); i += batchSize {
-		end := i + batchSize
-		if end > len(sessions) {
-			end = len(sessions)
-		}
-		batch := sessions[i:end]
-
-		payload := heartbeatPayload{Sessions: batch}
-		body, err := json.Marshal(payload)
-		if err != nil {
-			log.Printf("ERROR marshaling batch %d-%d: %v", i, end, err)
-			failed += len(batch)
-			continue
-		}
-
-		req, err := http.NewRequest("POST", cfg.Endpoint+"/api/sessions/heartbeat", bytes.NewReader(body))
-		if err != nil {
-			log.Printf("ERROR creating request: %v", err)
-			failed += len(batch)
-			continue
-		}
-		req.Header.Set("Authorization", "Bearer "+cfg.APIKey)
-		req.Header.Set("Content-Type", "application/json")
-
-		client := &http.Client{
-			Timeout: 30 * time.Second,
-			CheckRedirect: func(req *http.Request, via []*http.Request) error {
-				return http.ErrUseLastResponse // don't follow redirects
-			},
-		}
-		resp, err := client.Do(req)
-		if err != nil {
-			log.Printf("ERROR sending batch %d-%d: %v", i, end, err)
-			failed += len(batch)
-			continue
-		}
-
-		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-			respBody, _ := io.ReadAll(resp.Body)
-			resp.Body.Close()
-			log.Printf("ERROR batch %d-%d: API returned status %d, body: %s, url: %s", i, end, resp.StatusCode, string(respBody), req.URL.String())
-			failed += len(batch)
-			continue
-		}
-		resp.Body.Close()
-
-		sent += len(batch)
-		log.Printf("sent batch %d-%d (%d/%d)", i, end, sent, len(sessions))
+	dryRun := flag.Bool("dry-run", false, "list the sessions that would be sent without sending them")
+	resume := flag.Bool("resume", false, "skip sessions the ledger shows as already acknowledged")
+	batchSize := flag.Int("batch-size", 10, "sessions per heartbeat request (halved automatically if the server says it is too large)")
+	concurrency := flag.Int("concurrency", 4, "number of batches in flight at once")
+	rate := flag.Float64("rate", 0, "maximum requests per second across all workers (0 = unlimited)")
+	retry := claug.DefaultRetryPolicy
+	flag.IntVar(&retry.Budget, "retry-budget", retry.Budget, "total API retries allowed across the whole upload; raise it for long backfills")
+	flag.IntVar(&retry.MaxAttempts, "max-attempts", retry.MaxAttempts, "attempts per API request, including the first")
+	ledgerPath := flag.String("ledger", filepath.Join(ccLiveDir, "backfill.db"), "SQLite file recording which sessions were acknowledged")
+	dryRunOut := flag.String("dry-run-out", "", "with --dry-run, write the sessions to this file (- for stdout)")
+	dryRunFmt := flag.String("dry-run-format", "", "--dry-run-out format: jsonl (exact heartbeat payloads) or csv (summary); default from the file extension")
+	filterOpts := registerFilterFlags(flag.CommandLine)
+	policyOpts := registerPolicyFlags(flag.CommandLine)
+	flag.Parse()
+
+	filter, err := filterOpts.parse(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
 	}
-
-	log.Printf("backfill complete: %d sent, %d failed out of %d total", sent, failed, len(sessions))
-}
-
-func loadConfig() claugAuthConfig {
-	home, err := os.UserHomeDir()
+	policy, err := policyOpts.parse()
 	if err != nil {
-		log.Fatalf("getting home dir: %v", err)
+		log.Fatal(err)
+	}
+	format, err := dryRunFormat(*dryRunFmt, *dryRunOut)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := checkRate(*rate); err != nil {
+		log.Fatal(err)
 	}
 
-	authFile := filepath.Join(home, ".config", "claug", "auth.json")
-	data, err := os.ReadFile(authFile)
+	cfg, err := claug.Load()
 	if err != nil {
-		log.Fatalf("reading %s: %v\nRun 'claug login' to authenticate.", authFile, err)
+		log.Fatalf("loading claug config: %v", err)
 	}
+	sessions := readSQLiteSessions(filepath.Join(ccLiveDir, "state.db"))
 
-	var cfg claugAuthConfig
-	if err := json.Unmarshal(data, &cfg); err != nil {
-		log.Fatalf("parsing %s: %v", authFile, err)
+	log.Printf("found %d sessions in SQLite", len(sessions))
+	if filter.active() {
+		sessions = selectSessions(sessions, filter)
 	}
+	sessions, decisions := applyPolicy(sessions, policy)
 
-	if cfg.APIKey == "" {
-		log.Fatalf("no api_key found in %s", authFile)
+	var lg *ledger
+	if *resume || !*dryRun {
+		lg, err = openLedger(*ledgerPath, cfg.Endpoint)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer lg.Close()
 	}
-	if cfg.Endpoint == "" {
-		cfg.Endpoint = "https://api.claug.ai"
+	if *resume {
+		acked, err := lg.acked()
+		if err != nil {
+			log.Fatal(err)
+		}
+		before := len(sessions)
+		sessions = skipAcked(sessions, acked)
+		log.Printf("resuming: %d already acknowledged, %d left to send", before-len(sessions), len(sessions))
 	}
 
-	return cfg
-}
+	if *dryRun {
+		log.Printf("dry run — not sending to API")
+		for _, s := range sessions {
+			if *dryRunOut == "" {
+				log.Printf("  %s: date=%s project=%s privacy=%s tokens=%d tools=%d version=%s",
+					s.SessionID, formatUnix(s.CreatedAt), s.Project, s.PrivacyLevel, s.TotalTokens, s.ToolCalls, s.ProviderVersion)
+			}
+			for _, d := range decisions[s.SessionID] {
+				log.Printf("    %s policy: %s", s.SessionID, d)
+			}
+		}
+		if *dryRunOut != "" {
+			if err := writeDryRun(*dryRunOut, format, sessions, decisions); err != nil {
+				log.Fatal(err)
+			}
+			log.Printf("wrote %d sessions to %s as %s", len(sessions), *dryRunOut, format)
+		}
 
-func readSQLiteSessions() []sessionMetrics {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		log.Fatalf("getting home dir: %v", err)
+		// Totals go to stdout for diffing against the site's
+		// `jq .totals site/data/cc_sessions.json`, unless the sessions do.
+		totalsOut := os.Stdout
+		if *dryRunOut == "-" {
+			totalsOut = os.Stderr
+		}
+		enc := json.NewEncoder(totalsOut)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(backfillTotals(sessions)); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
 
-	dbPath := filepath.Join(home, ".cc-live", "state.db")
-	db, err := sql.Open("sqlite", dbPath)
+	client := claug.NewClient(cfg, retry)
+	sent, err := newUploader(client, lg, *batchSize, *concurrency, *rate).run(sessions)
 	if err != nil {
-		log.Fatalf("opening SQLite: %v", err)
+		log.Printf("ERROR %v", err)
 	}
-	defer db.Close()
 
-	rows, err := db.Query(`SELECT session_id, project, model, summary,
-		num_user_prompts, num_tool_calls, total_input_tokens, total_cache_read_input_tokens,
-		total_output_tokens, total_tokens, active_time_seconds, cc_version, sensitive,
-		tool_counts_json
-		FROM session_stats
-		WHERE total_tokens > 0
-		ORDER BY date DESC`)
+	missing, err := undelivered(lg, sessions)
 	if err != nil {
-		log.Fatalf("querying session_stats: %v", err)
+		log.Fatal(err)
 	}
-	defer rows.Close()
-
-	var sessions []sessionMetrics
-	for rows.Next() {
-		var (
-			sessionID      string
-			project        string
-			model          string
-			summary        string
-			userPrompts    int
-			toolCalls      int
-			inputTokens    int64
-			cacheReadTokens int64
-			outputTokens   int64
-			totalTokens    int64
-			activeTime     int
-			ccVersion      string
-			sensitive      int
-			toolCountsJSON string
-		)
-
-		if err := rows.Scan(&sessionID, &project, &model, &summary,
-			&userPrompts, &toolCalls, &inputTokens, &cacheReadTokens,
-			&outputTokens, &totalTokens, &activeTime, &ccVersion, &sensitive,
-			&toolCountsJSON); err != nil {
-			log.Printf("scanning row: %v", err)
-			continue
+	log.Printf("backfill complete: %d sent, %d not delivered out of %d total", sent, len(missing), len(sessions))
+	if len(missing) > 0 {
+		fmt.Fprintf(os.Stderr, "\n%d sessions never landed (rerun with --resume to retry them):\n", len(missing))
+		for _, e := range missing {
+			reason := "not attempted"
+			if e.Attempts > 0 {
+				reason = fmt.Sprintf("%d attempts, last error: %s", e.Attempts, e.LastError)
+			}
+			fmt.Fprintf(os.Stderr, "  %s  %s\n", e.SessionID, reason)
 		}
+		os.Exit(1)
+	}
+}
 
-		privacyLevel := "full"
-		if sensitive == 1 {
-			privacyLevel = "metrics_only"
-		}
+// selectSessions applies filter, warning about requested session IDs that
+// cc-live doesn't have.
+func selectSessions(sessions []claug.SessionMetrics, filter sessionFilter) []claug.SessionMetrics {
+	selected := filter.apply(sessions)
+	log.Printf("%d sessions match the filters", len(selected))
 
-		// Parse tool counts
-		var toolCounts map[string]int
-		if toolCountsJSON != "" && toolCountsJSON != "{}" {
-			if err := json.Unmarshal([]byte(toolCountsJSON), &toolCounts); err != nil {
-				toolCounts = nil
+	if filter.SessionIDs != nil {
+		found := make(map[string]bool, len(sessions))
+		for _, s := range sessions {
+			found[s.SessionID] = true
+		}
+		var unknown []string
+		for id := range filter.SessionIDs {
+			if !found[id] {
+				unknown = append(unknown, id)
 			}
 		}
-
-		// Clean project name from cwd if needed
-		if project == "" {
-			// project might not be stored; that's ok
+		if len(unknown) > 0 {
+			slices.Sort(unknown)
+			log.Printf("WARNING: %d requested session IDs are not in cc-live (or have no tokens): %s",
+				len(unknown), strings.Join(unknown, ", "))
 		}
-
-		_ = ccVersion // not used in heartbeat payload
-		_ = strings.TrimSpace(summary)
-
-		sessions = append(sessions, sessionMetrics{
-			SessionID:            sessionID,
-			TotalTokens:          totalTokens,
-			InputTokens:          inputTokens,
-			CacheReadInputTokens: cacheReadTokens,
-			OutputTokens:         outputTokens,
-			ToolCalls:            toolCalls,
-			ToolCounts:           toolCounts,
-			UserPrompts:          userPrompts,
-			ActiveTime:           activeTime,
-			Project:              project,
-			Model:                model,
-			Summary:              summary,
-			PrivacyLevel:         privacyLevel,
-		})
 	}
-
-	return sessions
+	return selected
 }
 
-func cleanToolName(name string) string {
-	parts := strings.Split(name, "__")
-	if len(parts) >= 3 && parts[0] == "mcp" {
-		providerParts := strings.Split(parts[1], "_")
-		service := providerParts[len(providerParts)-1]
-		return service + ": " + strings.Join(parts[2:], "__")
+// applyPolicy runs every session through policy, returning the decisions
+// made for each session ID.
+func applyPolicy(sessions []claug.SessionMetrics, policy privacyPolicy) ([]claug.SessionMetrics, map[string][]string) {
+	out := make([]claug.SessionMetrics, len(sessions))
+	decisions := make(map[string][]string)
+	for i, s := range sessions {
+		var d []string
+		out[i], d = policy.apply(s)
+		if len(d) > 0 {
+			decisions[s.SessionID] = d
+		}
 	}
-	return name
+	if len(decisions) > 0 {
+		log.Printf("privacy policy changed %d of %d sessions", len(decisions), len(sessions))
+	}
+	return out, decisions
 }
 
-func formatTokens(n int64) string {
-	if n >= 10_000_000 {
-		return fmt.Sprintf("%.1fM", float64(n)/1_000_000)
+func skipAcked(sessions []claug.SessionMetrics, acked map[string]bool) []claug.SessionMetrics {
+	var pending []claug.SessionMetrics
+	for _, s := range sessions {
+		if !acked[s.SessionID] {
+			pending = append(pending, s)
+		}
 	}
-	s := fmt.Sprintf("%d", n)
-	if n < 0 {
-		return s
+	return pending
+}
+
+// undelivered returns the ledger state of every session in sessions that the
+// API has not acknowledged, in input order. Sessions the upload never reached
+// have zero attempts.
+func undelivered(lg *ledger, sessions []claug.SessionMetrics) ([]ledgerEntry, error) {
+	ids := sessionIDs(sessions)
+	entries, err := lg.entries(ids)
+	if err != nil {
+		return nil, err
 	}
-	var result []byte
-	for i, c := range s {
-		if i > 0 && (len(s)-i)%3 == 0 {
-			result = append(result, ',')
+
+	var missing []ledgerEntry
+	for _, id := range ids {
+		e, ok := entries[id]
+		if !ok {
+			e = ledgerEntry{SessionID: id}
+		}
+		if e.Status != ledgerAcked {
+			missing = append(missing, e)
 		}
-		result = append(result, byte(c))
 	}
-	return string(result)
+	return missing, nil
+}
+
+func formatUnix(sec int64) string {
+	if sec == 0 {
+		return "-"
+	}
+	return time.Unix(sec, 0).UTC().Format(time.RFC3339)
 }
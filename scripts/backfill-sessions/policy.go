@@ -0,0 +1,133 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"regexp"
+
+	"github.com/howiewang/personal-blog/scripts/claug"
+)
+
+// privacyRank orders privacy levels from most to least public. Unknown
+// levels rank as metrics_only, matching how build-sessions renders them.
+var privacyRank = map[string]int{
+	"full":         0,
+	"metrics_only": 1,
+	"hidden":       2,
+}
+
+// privacyPolicy rewrites sessions before upload: it can raise every session
+// to a minimum privacy level, drop summaries and prompts that match
+// patterns (or all of them) and replace project names with stable hashes.
+type privacyPolicy struct {
+	Floor          string
+	RedactPatterns []*regexp.Regexp
+	StripText      bool
+	HashProjects   bool
+	HashSalt       string
+}
+
+// policyFlags holds the raw flag values until they are parsed.
+type policyFlags struct {
+	floor          string
+	redactPatterns claug.StringList
+	stripText      bool
+	hashProjects   bool
+	hashSalt       string
+}
+
+func registerPolicyFlags(fs *flag.FlagSet) *policyFlags {
+	p := &policyFlags{}
+	fs.StringVar(&p.floor, "privacy-floor", "full", "minimum privacy level for every session: full, metrics_only or hidden")
+	fs.Var(&p.redactPatterns, "redact-pattern", "drop the summary and last prompt of sessions matching this regexp (case-insensitive); repeatable")
+	fs.BoolVar(&p.stripText, "strip-summaries", false, "drop every summary and last prompt")
+	fs.BoolVar(&p.hashProjects, "hash-projects", false, "replace project names with a salted hash")
+	fs.StringVar(&p.hashSalt, "hash-salt", "", "secret salt for --hash-projects (required with it); keep it to get the same hashes on a rerun")
+	return p
+}
+
+func (p *policyFlags) parse() (privacyPolicy, error) {
+	if _, ok := privacyRank[p.floor]; !ok {
+		return privacyPolicy{}, fmt.Errorf("--privacy-floor: unknown level %q (want full, metrics_only or hidden)", p.floor)
+	}
+	if p.hashProjects && p.hashSalt == "" {
+		return privacyPolicy{}, fmt.Errorf("--hash-projects needs a --hash-salt; unsalted hashes of project names can be reversed by guessing names")
+	}
+	policy := privacyPolicy{
+		Floor:        p.floor,
+		StripText:    p.stripText,
+		HashProjects: p.hashProjects,
+		HashSalt:     p.hashSalt,
+	}
+	for _, expr := range p.redactPatterns {
+		re, err := regexp.Compile("(?i)" + expr)
+		if err != nil {
+			return privacyPolicy{}, fmt.Errorf("--redact-pattern %q: %w", expr, err)
+		}
+		policy.RedactPatterns = append(policy.RedactPatterns, re)
+	}
+	return policy, nil
+}
+
+// apply returns s as it should be uploaded along with a note for every
+// change made.
+func (p privacyPolicy) apply(s claug.SessionMetrics) (claug.SessionMetrics, []string) {
+	var decisions []string
+
+	if rankOf(s.PrivacyLevel) < rankOf(p.Floor) {
+		decisions = append(decisions, fmt.Sprintf("privacy %s -> %s (floor)", s.PrivacyLevel, p.Floor))
+		s.PrivacyLevel = p.Floor
+	}
+
+	// Like cc-live, only fully public sessions carry verbatim text, so a
+	// raised floor drops it too.
+	if s.Summary != "" || s.LastPrompt != "" {
+		if rankOf(s.PrivacyLevel) > privacyRank["full"] {
+			decisions = append(decisions, fmt.Sprintf("summary and last prompt dropped (%s)", s.PrivacyLevel))
+			s.Summary, s.LastPrompt = "", ""
+		} else if p.StripText {
+			decisions = append(decisions, "summary and last prompt stripped")
+			s.Summary, s.LastPrompt = "", ""
+		} else if field, re := p.redactMatch(s); re != nil {
+			decisions = append(decisions, fmt.Sprintf("summary and last prompt redacted (%s matches %s)", field, re))
+			s.Summary, s.LastPrompt = "", ""
+		}
+	}
+
+	if p.HashProjects && s.Project != "" {
+		hashed := p.hashProject(s.Project)
+		decisions = append(decisions, fmt.Sprintf("project %s -> %s", s.Project, hashed))
+		s.Project = hashed
+	}
+	return s, decisions
+}
+
+// redactMatch returns the first redact pattern matching the summary or the
+// last prompt, and which of the two it matched.
+func (p privacyPolicy) redactMatch(s claug.SessionMetrics) (field string, re *regexp.Regexp) {
+	for _, re := range p.RedactPatterns {
+		if re.MatchString(s.Summary) {
+			return "summary", re
+		}
+		if re.MatchString(s.LastPrompt) {
+			return "last prompt", re
+		}
+	}
+	return "", nil
+}
+
+// hashProject hashes the project's last path element, so the same repo
+// checked out in different places still groups together.
+func (p privacyPolicy) hashProject(project string) string {
+	sum := sha256.Sum256([]byte(p.HashSalt + claug.ProjectName(project)))
+	return "project-" + hex.EncodeToString(sum[:])[:10]
+}
+
+func rankOf(level string) int {
+	if r, ok := privacyRank[level]; ok {
+		return r
+	}
+	return privacyRank["metrics_only"]
+}
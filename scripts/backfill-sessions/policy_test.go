@@ -0,0 +1,106 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/howiewang/personal-blog/scripts/claug"
+)
+
+func mustPolicy(t *testing.T, p policyFlags) privacyPolicy {
+	t.Helper()
+	if p.floor == "" {
+		p.floor = "full"
+	}
+	policy, err := p.parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return policy
+}
+
+func TestPrivacyPolicyFloor(t *testing.T) {
+	policy := mustPolicy(t, policyFlags{floor: "metrics_only"})
+	tests := []struct{ in, want string }{
+		{"full", "metrics_only"},
+		{"metrics_only", "metrics_only"},
+		{"hidden", "hidden"}, // never loosened
+		{"something_new", "something_new"},
+	}
+	for _, tt := range tests {
+		got, decisions := policy.apply(claug.SessionMetrics{PrivacyLevel: tt.in})
+		if got.PrivacyLevel != tt.want {
+			t.Errorf("floor on %s = %s, want %s", tt.in, got.PrivacyLevel, tt.want)
+		}
+		if changed := tt.in != tt.want; changed != (len(decisions) == 1) {
+			t.Errorf("floor on %s: decisions = %v", tt.in, decisions)
+		}
+
+		got, _ = policy.apply(claug.SessionMetrics{PrivacyLevel: tt.in, Summary: "Fix login bug", LastPrompt: "the token is abc123"})
+		if got.Summary != "" || got.LastPrompt != "" {
+			t.Errorf("floor on %s kept the summary or last prompt: %+v", tt.in, got)
+		}
+	}
+
+	// A full session above the floor keeps its text.
+	public := mustPolicy(t, policyFlags{floor: "full"})
+	if got, decisions := public.apply(claug.SessionMetrics{PrivacyLevel: "full", Summary: "s", LastPrompt: "p"}); got.Summary != "s" || got.LastPrompt != "p" || len(decisions) != 0 {
+		t.Errorf("full session changed: %+v %v", got, decisions)
+	}
+}
+
+func TestPrivacyPolicyRedaction(t *testing.T) {
+	policy := mustPolicy(t, policyFlags{redactPatterns: claug.StringList{"acme", `\.internal\b`}})
+	s := claug.SessionMetrics{Summary: "Deploy to db1.internal", LastPrompt: "go", PrivacyLevel: "full"}
+	got, decisions := policy.apply(s)
+	if got.Summary != "" || got.LastPrompt != "" {
+		t.Errorf("matching session not redacted: %+v", got)
+	}
+	if len(decisions) != 1 || !strings.Contains(decisions[0], `summary matches (?i)\.internal\b`) {
+		t.Errorf("decisions = %v", decisions)
+	}
+
+	s = claug.SessionMetrics{Summary: "Refactor parser", LastPrompt: "ask ACME about it", PrivacyLevel: "full"}
+	if got, _ := policy.apply(s); got.Summary != "" {
+		t.Errorf("last prompt match (case-insensitive) should redact the summary too: %+v", got)
+	}
+
+	s = claug.SessionMetrics{Summary: "Refactor parser", LastPrompt: "looks good", PrivacyLevel: "full"}
+	if got, decisions := policy.apply(s); got.Summary != s.Summary || got.LastPrompt != s.LastPrompt || len(decisions) != 0 {
+		t.Errorf("non-matching session changed: %+v %v", got, decisions)
+	}
+
+	strip := mustPolicy(t, policyFlags{stripText: true})
+	if got, _ := strip.apply(claug.SessionMetrics{Summary: "anything"}); got.Summary != "" {
+		t.Error("--strip-summaries kept a summary")
+	}
+}
+
+func TestPrivacyPolicyHashProjects(t *testing.T) {
+	policy := mustPolicy(t, policyFlags{hashProjects: true, hashSalt: "pepper"})
+	a, _ := policy.apply(claug.SessionMetrics{Project: "/Users/howie/code/claug"})
+	b, _ := policy.apply(claug.SessionMetrics{Project: `C:\src\claug`})
+	c, _ := policy.apply(claug.SessionMetrics{Project: "personal-blog"})
+	if a.Project != b.Project || a.Project == c.Project || !strings.HasPrefix(a.Project, "project-") {
+		t.Errorf("hashes: %q %q %q", a.Project, b.Project, c.Project)
+	}
+	salted := mustPolicy(t, policyFlags{hashProjects: true, hashSalt: "salt"})
+	if d, _ := salted.apply(claug.SessionMetrics{Project: "claug"}); d.Project == a.Project {
+		t.Error("salt did not change the hash")
+	}
+	if e, decisions := policy.apply(claug.SessionMetrics{}); e.Project != "" || len(decisions) != 0 {
+		t.Errorf("empty project hashed: %+v", e)
+	}
+}
+
+func TestPolicyFlagsParseErrors(t *testing.T) {
+	for _, bad := range []policyFlags{
+		{floor: "secret"},
+		{floor: "full", redactPatterns: claug.StringList{"("}},
+		{floor: "full", hashProjects: true}, // no salt
+	} {
+		if _, err := bad.parse(); err == nil {
+			t.Errorf("parse(%+v) succeeded, want error", bad)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/howiewang/personal-blog/scripts/claug"
+)
+
+// stateSchemas describes how cc-live's session_stats table grew. Each
+// version lists the columns it added on top of the previous one; a database
+// is at the highest version whose columns are all present.
+var stateSchemas = []struct {
+	version int
+	added   []string
+}{
+	{1, []string{"session_id", "date", "project", "model", "summary", "num_user_prompts",
+		"num_tool_calls", "total_input_tokens", "total_output_tokens", "total_tokens",
+		"active_time_seconds", "sensitive"}},
+	{2, []string{"total_cache_read_input_tokens", "cc_version"}},
+	{3, []string{"tool_counts_json"}},
+	{4, []string{"last_prompt"}},
+}
+
+// stateSchema is what the reader found in a cc-live database.
+type stateSchema struct {
+	Version     int      // 0 if even the v1 columns are incomplete
+	UserVersion int      // PRAGMA user_version, for the report only
+	Missing     []string // known columns the table lacks
+	Unknown     []string // columns the reader ignores
+}
+
+func (s stateSchema) String() string {
+	out := fmt.Sprintf("cc-live schema v%d (user_version %d)", s.Version, s.UserVersion)
+	if len(s.Missing) > 0 {
+		out += "; missing " + strings.Join(s.Missing, ", ") + " (using defaults)"
+	}
+	if len(s.Unknown) > 0 {
+		out += "; ignoring " + strings.Join(s.Unknown, ", ")
+	}
+	return out
+}
+
+// stateRow holds one session_stats row. Every column is nullable so rows
+// written by any cc-live version scan cleanly.
+type stateRow struct {
+	sessionID                                                    string
+	date, project, model, summary, ccVersion, toolCounts, prompt sql.NullString
+	userPrompts, toolCalls, input, cacheRead, output, total      sql.NullInt64
+	activeTime, sensitive                                        sql.NullInt64
+}
+
+// dest returns the scan destination for a known column, or nil.
+func (r *stateRow) dest(column string) any {
+	switch column {
+	case "session_id":
+		return &r.sessionID
+	case "date":
+		return &r.date
+	case "project":
+		return &r.project
+	case "model":
+		return &r.model
+	case "summary":
+		return &r.summary
+	case "num_user_prompts":
+		return &r.userPrompts
+	case "num_tool_calls":
+		return &r.toolCalls
+	case "total_input_tokens":
+		return &r.input
+	case "total_cache_read_input_tokens":
+		return &r.cacheRead
+	case "total_output_tokens":
+		return &r.output
+	case "total_tokens":
+		return &r.total
+	case "active_time_seconds":
+		return &r.activeTime
+	case "cc_version":
+		return &r.ccVersion
+	case "sensitive":
+		return &r.sensitive
+	case "tool_counts_json":
+		return &r.toolCounts
+	case "last_prompt":
+		return &r.prompt
+	}
+	return nil
+}
+
+// readSQLiteSessions reads every session with tokens from the cc-live
+// database at dbPath, whatever its schema version.
+func readSQLiteSessions(dbPath string) []claug.SessionMetrics {
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		log.Fatalf("opening SQLite: %v", err)
+	}
+	defer db.Close()
+
+	sessions, schema, err := readSessions(db)
+	if err != nil {
+		log.Fatalf("reading %s: %v", dbPath, err)
+	}
+	log.Printf("detected %s", schema)
+	return sessions
+}
+
+func readSessions(db *sql.DB) ([]claug.SessionMetrics, stateSchema, error) {
+	columns, schema, err := inspectSchema(db)
+	if err != nil {
+		return nil, schema, err
+	}
+
+	query := "SELECT " + strings.Join(columns, ", ") + " FROM session_stats"
+	if slices.Contains(columns, "date") {
+		query += " ORDER BY date DESC"
+	}
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, schema, fmt.Errorf("querying session_stats: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []claug.SessionMetrics
+	for rows.Next() {
+		var r stateRow
+		dests := make([]any, len(columns))
+		for i, c := range columns {
+			dests[i] = r.dest(c)
+		}
+		if err := rows.Scan(dests...); err != nil {
+			log.Printf("scanning row: %v", err)
+			continue
+		}
+		if s := r.metrics(); s.TotalTokens > 0 {
+			sessions = append(sessions, s)
+		}
+	}
+	return sessions, schema, rows.Err()
+}
+
+// inspectSchema returns the known columns session_stats has, in table order,
+// and the schema they add up to.
+func inspectSchema(db *sql.DB) ([]string, stateSchema, error) {
+	var schema stateSchema
+	if err := db.QueryRow("PRAGMA user_version").Scan(&schema.UserVersion); err != nil {
+		return nil, schema, fmt.Errorf("reading user_version: %w", err)
+	}
+
+	rows, err := db.Query("SELECT name FROM pragma_table_info('session_stats')")
+	if err != nil {
+		return nil, schema, fmt.Errorf("inspecting session_stats: %w", err)
+	}
+	defer rows.Close()
+
+	var known []string
+	present := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, schema, fmt.Errorf("inspecting session_stats: %w", err)
+		}
+		name = strings.ToLower(name)
+		present[name] = true
+		if (&stateRow{}).dest(name) != nil {
+			known = append(known, name)
+		} else {
+			schema.Unknown = append(schema.Unknown, name)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, schema, err
+	}
+	if len(present) == 0 {
+		return nil, schema, fmt.Errorf("no session_stats table")
+	}
+	if !present["session_id"] {
+		return nil, schema, fmt.Errorf("session_stats has no session_id column")
+	}
+
+	complete := true
+	for _, v := range stateSchemas {
+		for _, c := range v.added {
+			if !present[c] {
+				complete = false
+				schema.Missing = append(schema.Missing, c)
+			}
+		}
+		if complete {
+			schema.Version = v.version
+		}
+	}
+	return known, schema, nil
+}
+
+// metrics converts a row to a heartbeat, filling in what older schemas lack:
+// total tokens from the parts, tool calls from the tool counts and full
+// privacy when there is no sensitive flag.
+func (r *stateRow) metrics() claug.SessionMetrics {
+	privacyLevel := "full"
+	if r.sensitive.Int64 == 1 {
+		privacyLevel = "metrics_only"
+	}
+
+	var toolCounts map[string]int
+	if tc := r.toolCounts.String; tc != "" && tc != "{}" {
+		if err := json.Unmarshal([]byte(tc), &toolCounts); err != nil {
+			toolCounts = nil
+		}
+	}
+
+	total := r.total.Int64
+	if !r.total.Valid {
+		total = r.input.Int64 + r.cacheRead.Int64 + r.output.Int64
+	}
+	toolCalls := int(r.toolCalls.Int64)
+	if !r.toolCalls.Valid {
+		for _, n := range toolCounts {
+			toolCalls += n
+		}
+	}
+
+	createdAt, err := parseSessionDate(r.date.String)
+	if err != nil {
+		log.Printf("session %s: %v; the server will stamp it with the upload time", r.sessionID, err)
+	}
+
+	// The last prompt is verbatim user input, so only sessions cc-live
+	// considers fully public carry it.
+	lastPrompt := strings.TrimSpace(r.prompt.String)
+	if privacyLevel != "full" {
+		lastPrompt = ""
+	}
+
+	return claug.SessionMetrics{
+		SessionID:            r.sessionID,
+		TotalTokens:          total,
+		InputTokens:          r.input.Int64,
+		CacheReadInputTokens: r.cacheRead.Int64,
+		OutputTokens:         r.output.Int64,
+		ToolCalls:            toolCalls,
+		ToolCounts:           toolCounts,
+		UserPrompts:          int(r.userPrompts.Int64),
+		ActiveTime:           int(r.activeTime.Int64),
+		Project:              r.project.String,
+		Model:                r.model.String,
+		Summary:              r.summary.String,
+		PrivacyLevel:         privacyLevel,
+		LastPrompt:           lastPrompt,
+		CreatedAt:            createdAt,
+		ProviderVersion:      r.ccVersion.String,
+	}
+}
+
+// sessionDateLayouts are the formats cc-live has stored session_stats.date
+// in, tried in order.
+var sessionDateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// parseSessionDate converts a session_stats.date value to unix seconds. Values
+// without a zone are UTC; bare integers are already unix seconds. An empty
+// date returns 0.
+func parseSessionDate(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n, nil
+	}
+	for _, layout := range sessionDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Unix(), nil
+		}
+	}
+	return 0, fmt.Errorf("unrecognized date %q", s)
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/howiewang/personal-blog/scripts/claug"
+)
+
+// writeStateDB creates a cc-live state.db at path from a schema and rows.
+func writeStateDB(t *testing.T, path, schema string, rows ...string) {
+	t.Helper()
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, stmt := range append([]string{schema}, rows...) {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+}
+
+const currentSchema = `CREATE TABLE session_stats (
+	session_id TEXT PRIMARY KEY, date TEXT, project TEXT, model TEXT, summary TEXT,
+	num_user_prompts INTEGER, num_tool_calls INTEGER, total_input_tokens INTEGER,
+	total_cache_read_input_tokens INTEGER, total_output_tokens INTEGER, total_tokens INTEGER,
+	active_time_seconds INTEGER, cc_version TEXT, sensitive INTEGER, tool_counts_json TEXT,
+	last_prompt TEXT)`
+
+func TestReadSQLiteSessionsKeepsDateVersionAndPrompt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.db")
+	writeStateDB(t, path, currentSchema,
+		`INSERT INTO session_stats VALUES ('pub', '2026-02-01T10:00:00Z', 'blog', 'opus', 'did a thing',
+			2, 3, 10, 20, 30, 60, 120, '2.1.4', 0, '{"Read":3}', ' fix the header ')`,
+		`INSERT INTO session_stats VALUES ('sens', '2026-02-02 09:30:00', 'client', 'opus', 'secret',
+			1, 0, 1, 1, 1, 3, 10, '2.1.5', 1, '', 'customer password reset')`,
+		`INSERT INTO session_stats VALUES ('empty', '2026-02-03', 'x', 'opus', '', 0, 0, 0, 0, 0, 0, 0, '', 0, '', NULL)`,
+	)
+
+	got := make(map[string]int)
+	sessions := readSQLiteSessions(path)
+	for i, s := range sessions {
+		got[s.SessionID] = i
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("read %d sessions, want 2 (zero-token rows skipped)", len(sessions))
+	}
+
+	pub := sessions[got["pub"]]
+	if want := time.Date(2026, 2, 1, 10, 0, 0, 0, time.UTC).Unix(); pub.CreatedAt != want {
+		t.Errorf("pub.CreatedAt = %d, want %d", pub.CreatedAt, want)
+	}
+	if pub.ProviderVersion != "2.1.4" || pub.LastPrompt != "fix the header" {
+		t.Errorf("pub = %+v", pub)
+	}
+
+	sens := sessions[got["sens"]]
+	if sens.PrivacyLevel != "metrics_only" || sens.LastPrompt != "" {
+		t.Errorf("sensitive session leaked its prompt: %+v", sens)
+	}
+	if want := time.Date(2026, 2, 2, 9, 30, 0, 0, time.UTC).Unix(); sens.CreatedAt != want {
+		t.Errorf("sens.CreatedAt = %d, want %d", sens.CreatedAt, want)
+	}
+}
+
+func TestParseSessionDate(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+		err  bool
+	}{
+		{"", 0, false},
+		{"1770000000", 1770000000, false},
+		{"2026-02-01T10:00:00-06:00", time.Date(2026, 2, 1, 16, 0, 0, 0, time.UTC).Unix(), false},
+		{"2026-02-01T10:00:00.123Z", time.Date(2026, 2, 1, 10, 0, 0, 0, time.UTC).Unix(), false},
+		{"2026-02-01", time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC).Unix(), false},
+		{"Feb 1", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseSessionDate(tt.in)
+		if got != tt.want || (err != nil) != tt.err {
+			t.Errorf("parseSessionDate(%q) = %d, %v; want %d, err=%v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestReadSessionsOldSchemas(t *testing.T) {
+	tests := []struct {
+		name        string
+		schema      string
+		insert      string
+		wantVersion int
+		wantMissing string
+		check       func(t *testing.T, s claug.SessionMetrics)
+	}{
+		{
+			name: "v1 without cache reads, version or tool counts",
+			schema: `CREATE TABLE session_stats (session_id TEXT, date TEXT, project TEXT, model TEXT,
+				summary TEXT, num_user_prompts INT, num_tool_calls INT, total_input_tokens INT,
+				total_output_tokens INT, total_tokens INT, active_time_seconds INT, sensitive INT)`,
+			insert:      `INSERT INTO session_stats VALUES ('a', '2026-01-05', 'p', 'm', 's', 1, 4, 10, 20, 30, 60, 0)`,
+			wantVersion: 1,
+			wantMissing: "total_cache_read_input_tokens,cc_version,tool_counts_json,last_prompt",
+			check: func(t *testing.T, s claug.SessionMetrics) {
+				if s.TotalTokens != 30 || s.ToolCalls != 4 || s.CacheReadInputTokens != 0 || s.ToolCounts != nil {
+					t.Errorf("v1 session = %+v", s)
+				}
+			},
+		},
+		{
+			name: "v3 with tool counts but no last_prompt, plus an unknown column",
+			schema: `CREATE TABLE session_stats (session_id TEXT, date TEXT, project TEXT, model TEXT,
+				summary TEXT, num_user_prompts INT, num_tool_calls INT, total_input_tokens INT,
+				total_cache_read_input_tokens INT, total_output_tokens INT, total_tokens INT,
+				active_time_seconds INT, cc_version TEXT, sensitive INT, tool_counts_json TEXT, branch TEXT)`,
+			insert: `INSERT INTO session_stats VALUES ('a', '2026-01-05', 'p', 'm', 's', 1, 2, 10, 5, 20, 35, 60,
+				'1.0.9', 1, '{"Bash":2}', 'main')`,
+			wantVersion: 3,
+			wantMissing: "last_prompt",
+			check: func(t *testing.T, s claug.SessionMetrics) {
+				if s.ProviderVersion != "1.0.9" || s.ToolCounts["Bash"] != 2 || s.PrivacyLevel != "metrics_only" {
+					t.Errorf("v3 session = %+v", s)
+				}
+			},
+		},
+		{
+			name: "pre-v1 without total_tokens, num_tool_calls or sensitive",
+			schema: `CREATE TABLE session_stats (session_id TEXT, project TEXT, total_input_tokens INT,
+				total_output_tokens INT, tool_counts_json TEXT)`,
+			insert:      `INSERT INTO session_stats VALUES ('a', 'p', 10, 20, '{"Read":2,"Edit":1}')`,
+			wantVersion: 0,
+			check: func(t *testing.T, s claug.SessionMetrics) {
+				if s.TotalTokens != 30 || s.ToolCalls != 3 || s.PrivacyLevel != "full" || s.CreatedAt != 0 {
+					t.Errorf("pre-v1 session = %+v", s)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "state.db")
+			writeStateDB(t, path, tt.schema, tt.insert)
+			db, err := sql.Open("sqlite", path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			sessions, schema, err := readSessions(db)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if schema.Version != tt.wantVersion {
+				t.Errorf("Version = %d, want %d (%s)", schema.Version, tt.wantVersion, schema)
+			}
+			if tt.wantMissing != "" && strings.Join(schema.Missing, ",") != tt.wantMissing {
+				t.Errorf("Missing = %v, want %s", schema.Missing, tt.wantMissing)
+			}
+			if len(sessions) != 1 {
+				t.Fatalf("read %d sessions, want 1", len(sessions))
+			}
+			tt.check(t, sessions[0])
+		})
+	}
+}
+
+func TestReadSessionsRequiresSessionTable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.db")
+	writeStateDB(t, path, `CREATE TABLE other (id TEXT)`)
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, _, err := readSessions(db); err == nil {
+		t.Error("readSessions succeeded without a session_stats table")
+	}
+}
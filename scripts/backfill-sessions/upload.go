@@ -0,0 +1,176 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/howiewang/personal-blog/scripts/claug"
+)
+
+// uploader sends heartbeat batches from a pool of workers sharing one
+// client. Outcomes are recorded in the ledger by a single goroutine as they
+// arrive, so an interrupted upload can always be resumed.
+type uploader struct {
+	client      *claug.Client
+	ledger      *ledger
+	batchSize   int
+	concurrency int
+	// rate caps requests per second across all workers; 0 means unlimited.
+	rate float64
+	logf func(format string, args ...any)
+}
+
+// batchResult is the final outcome of sending some sessions, which may be
+// a fragment of a dispatched batch after a 413 split.
+type batchResult struct {
+	sessions []claug.SessionMetrics
+	err      error
+}
+
+func newUploader(client *claug.Client, lg *ledger, batchSize, concurrency int, rate float64) *uploader {
+	return &uploader{
+		client:      client,
+		ledger:      lg,
+		batchSize:   max(batchSize, 1),
+		concurrency: max(concurrency, 1),
+		rate:        rate,
+		logf:        log.Printf,
+	}
+}
+
+// run uploads sessions and returns how many were acknowledged. Failed batches
+// are skipped so the rest can still land; an auth failure stops dispatching
+// new batches, and a ledger write error stops the upload.
+func (u *uploader) run(sessions []claug.SessionMetrics) (sent int, err error) {
+	throttle, stopThrottle := u.throttle()
+	defer stopThrottle()
+
+	jobs := make(chan []claug.SessionMetrics)
+	results := make(chan batchResult)
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+	halt := func() { stopOnce.Do(func() { close(stop) }) }
+
+	go func() {
+		defer close(jobs)
+		for i := 0; i < len(sessions); i += u.batchSize {
+			select {
+			case jobs <- sessions[i:min(i+u.batchSize, len(sessions))]:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for range u.concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range jobs {
+				u.send(batch, throttle, stop, func(r batchResult) {
+					// Halt here, not only in the collector, so this worker
+					// can't pick up another batch first.
+					var ae *claug.AuthError
+					if errors.As(r.err, &ae) {
+						halt()
+					}
+					results <- r
+				})
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var ledgerErr error
+	for r := range results {
+		if ledgerErr != nil {
+			continue // draining in-flight batches; they can't be recorded
+		}
+		if ledgerErr = u.ledger.record(sessionIDs(r.sessions), r.err); ledgerErr != nil {
+			halt()
+			continue
+		}
+		var ae *claug.AuthError
+		switch {
+		case errors.As(r.err, &ae):
+			if err == nil {
+				err = fmt.Errorf("stopping upload: %w", r.err)
+			}
+		case r.err != nil:
+			u.logf("ERROR batch %s: %v", batchLabel(r.sessions), r.err)
+		default:
+			sent += len(r.sessions)
+			u.logf("sent batch %s (%d/%d)", batchLabel(r.sessions), sent, len(sessions))
+		}
+	}
+	if ledgerErr != nil {
+		return sent, ledgerErr
+	}
+	return sent, err
+}
+
+// send posts batch, splitting it in half and retrying each half whenever the
+// server rejects it as too large. Every final outcome goes to report; nothing
+// is sent, or reported, once stop is closed.
+func (u *uploader) send(batch []claug.SessionMetrics, throttle func(), stop <-chan struct{}, report func(batchResult)) {
+	select {
+	case <-stop:
+		return
+	default:
+	}
+	throttle()
+	err := u.client.PostHeartbeat(batch)
+	var se *claug.StatusError
+	if errors.As(err, &se) && se.StatusCode == http.StatusRequestEntityTooLarge && len(batch) > 1 {
+		mid := len(batch) / 2
+		u.logf("batch %s too large, splitting into %d + %d", batchLabel(batch), mid, len(batch)-mid)
+		u.send(batch[:mid], throttle, stop, report)
+		u.send(batch[mid:], throttle, stop, report)
+		return
+	}
+	report(batchResult{sessions: batch, err: err})
+}
+
+// checkRate rejects a --rate the ticker in throttle can't honor: negative,
+// not a number, or so high its interval rounds down to zero.
+func checkRate(rate float64) error {
+	if rate == 0 {
+		return nil
+	}
+	if !(rate > 0) || time.Duration(float64(time.Second)/rate) <= 0 {
+		return fmt.Errorf("--rate %g: want 0 (unlimited) or a positive rate of at most %d requests per second", rate, int64(time.Second))
+	}
+	return nil
+}
+
+// throttle returns a function that blocks until the next request may start.
+func (u *uploader) throttle() (wait func(), stop func()) {
+	if u.rate <= 0 {
+		return func() {}, func() {}
+	}
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / u.rate))
+	return func() { <-ticker.C }, ticker.Stop
+}
+
+func sessionIDs(sessions []claug.SessionMetrics) []string {
+	ids := make([]string, len(sessions))
+	for i, s := range sessions {
+		ids[i] = s.SessionID
+	}
+	return ids
+}
+
+func batchLabel(batch []claug.SessionMetrics) string {
+	if len(batch) == 1 {
+		return batch[0].SessionID
+	}
+	return fmt.Sprintf("%s..%s (%d)", batch[0].SessionID, batch[len(batch)-1].SessionID, len(batch))
+}
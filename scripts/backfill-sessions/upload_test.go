@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"testing"
+
+	"github.com/howiewang/personal-blog/scripts/claug"
+	"github.com/howiewang/personal-blog/scripts/claug/claugtest"
+)
+
+func newTestUploader(client *claug.Client, lg *ledger, batchSize, concurrency int) *uploader {
+	u := newUploader(client, lg, batchSize, concurrency, 0)
+	u.logf = func(string, ...any) {}
+	return u
+}
+
+func TestUploadResumesFailedBatches(t *testing.T) {
+	srv := claugtest.NewServer()
+	defer srv.Close()
+	client := srv.Client(claug.DefaultRetryPolicy)
+	lg := testLedger(t, srv.URL)
+	sessions := testMetrics(25)
+
+	// Not retryable, so the first batch fails for good.
+	srv.FailNext(claugtest.Failure{Status: http.StatusBadRequest})
+	sent, err := newTestUploader(client, lg, 10, 1).run(sessions)
+	if err != nil || sent != 15 {
+		t.Fatalf("upload sent %d, err %v; want 15, nil", sent, err)
+	}
+	missing, err := undelivered(lg, sessions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(missing) != 10 || missing[0].SessionID != "s00" || missing[0].Attempts != 1 {
+		t.Fatalf("undelivered = %+v, want s00-s09 after one attempt", missing)
+	}
+
+	acked, err := lg.acked()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending := skipAcked(sessions, acked)
+	if len(pending) != 10 {
+		t.Fatalf("resume would send %d sessions, want 10", len(pending))
+	}
+	if sent, err := newTestUploader(client, lg, 10, 1).run(pending); err != nil || sent != 10 {
+		t.Fatalf("resume sent %d, err %v", sent, err)
+	}
+	if missing, _ := undelivered(lg, sessions); len(missing) != 0 {
+		t.Errorf("still undelivered after resume: %+v", missing)
+	}
+	if n := len(srv.Heartbeats()); n != 3 {
+		t.Errorf("server accepted %d batches, want 3", n)
+	}
+}
+
+func TestUploadStopsOnAuthError(t *testing.T) {
+	srv := claugtest.NewServer()
+	defer srv.Close()
+	lg := testLedger(t, srv.URL)
+	sessions := testMetrics(25)
+
+	srv.FailNext(claugtest.Failure{Status: http.StatusUnauthorized})
+	sent, err := newTestUploader(srv.Client(claug.DefaultRetryPolicy), lg, 10, 1).run(sessions)
+	var ae *claug.AuthError
+	if sent != 0 || !errors.As(err, &ae) {
+		t.Fatalf("upload sent %d, err %v; want 0 and an AuthError", sent, err)
+	}
+	missing, err := undelivered(lg, sessions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(missing) != 25 || missing[24].Attempts != 0 {
+		t.Errorf("undelivered = %d sessions (last %+v), want all 25 with the tail unattempted", len(missing), missing[len(missing)-1])
+	}
+}
+
+func TestUploadSplitsOversizedBatches(t *testing.T) {
+	srv := claugtest.NewServer()
+	defer srv.Close()
+	srv.SetMaxBatch(3)
+	lg := testLedger(t, srv.URL)
+	sessions := testMetrics(50)
+
+	sent, err := newTestUploader(srv.Client(claug.DefaultRetryPolicy), lg, 10, 4).run(sessions)
+	if err != nil || sent != 50 {
+		t.Fatalf("upload sent %d, err %v; want 50, nil", sent, err)
+	}
+	if missing, _ := undelivered(lg, sessions); len(missing) != 0 {
+		t.Errorf("undelivered after splitting: %+v", missing)
+	}
+
+	got := make(map[string]int)
+	for _, b := range srv.Heartbeats() {
+		if len(b) > 3 {
+			t.Errorf("server accepted a batch of %d", len(b))
+		}
+		for _, s := range b {
+			got[s.SessionID]++
+		}
+	}
+	for _, s := range sessions {
+		if got[s.SessionID] != 1 {
+			t.Errorf("%s accepted %d times, want once", s.SessionID, got[s.SessionID])
+		}
+	}
+}
+
+func TestUploadSingleSessionTooLarge(t *testing.T) {
+	srv := claugtest.NewServer()
+	defer srv.Close()
+	srv.FailNext(claugtest.Failure{Status: http.StatusRequestEntityTooLarge})
+	lg := testLedger(t, srv.URL)
+
+	sent, err := newTestUploader(srv.Client(claug.DefaultRetryPolicy), lg, 1, 1).run(testMetrics(2))
+	if err != nil || sent != 1 {
+		t.Fatalf("upload sent %d, err %v; want 1, nil", sent, err)
+	}
+	missing, _ := undelivered(lg, testMetrics(2))
+	if len(missing) != 1 || missing[0].SessionID != "s00" {
+		t.Errorf("undelivered = %+v, want s00", missing)
+	}
+}
+
+func TestCheckRate(t *testing.T) {
+	for _, ok := range []float64{0, 0.5, 20, 1e9} {
+		if err := checkRate(ok); err != nil {
+			t.Errorf("checkRate(%g) = %v, want nil", ok, err)
+		}
+	}
+	for _, bad := range []float64{-1, 2e9, math.Inf(1), math.NaN()} {
+		if err := checkRate(bad); err == nil {
+			t.Errorf("checkRate(%g) succeeded, want error", bad)
+		}
+	}
+}
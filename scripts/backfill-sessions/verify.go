@@ -0,0 +1,175 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/howiewang/personal-blog/scripts/claug"
+)
+
+// fieldDiff is one field whose value differs between cc-live and claug.
+type fieldDiff struct {
+	Field         string
+	Local, Remote string
+}
+
+type sessionDiff struct {
+	SessionID string
+	Fields    []fieldDiff
+}
+
+// reconciliation compares the cc-live sessions with what the claug API
+// returns for them.
+type reconciliation struct {
+	Local, Remote int
+	Matched       int
+	Mismatched    []sessionDiff
+	Missing       []string // in cc-live, not in claug
+	Extra         []string // in claug, not in cc-live
+}
+
+// runVerify implements `verify`: it diffs every cc-live session against the
+// API and exits non-zero when they disagree.
+func runVerify(ccLiveDir string, args []string) {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	ignoreExtra := fs.Bool("ignore-extra", false, "don't fail on sessions that exist only in claug (e.g. recorded live after the switchover)")
+	filterOpts := registerFilterFlags(fs)
+	policyOpts := registerPolicyFlags(fs)
+	fs.Parse(args)
+	filter, err := filterOpts.parse(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Compare against what the backfill sent, not the raw cc-live rows.
+	policy, err := policyOpts.parse()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	cfg, err := claug.Load()
+	if err != nil {
+		log.Fatalf("loading claug config: %v", err)
+	}
+	local, _ := applyPolicy(filter.apply(readSQLiteSessions(filepath.Join(ccLiveDir, "state.db"))), policy)
+	remote, err := claug.NewClient(cfg, claug.DefaultRetryPolicy).ListSessions(filter.query())
+	if err != nil {
+		log.Fatalf("fetching sessions: %v", err)
+	}
+	remote = filter.applyRemote(remote, local)
+
+	r := reconcile(local, remote)
+	r.write(os.Stdout)
+	if !r.ok(*ignoreExtra) {
+		os.Exit(1)
+	}
+}
+
+func reconcile(local []claug.SessionMetrics, remote []claug.SessionStats) reconciliation {
+	r := reconciliation{Local: len(local), Remote: len(remote)}
+	byID := make(map[string]claug.SessionStats, len(remote))
+	for _, s := range remote {
+		byID[s.SessionID] = s
+	}
+
+	seen := make(map[string]bool, len(local))
+	for _, l := range local {
+		seen[l.SessionID] = true
+		s, ok := byID[l.SessionID]
+		if !ok {
+			r.Missing = append(r.Missing, l.SessionID)
+			continue
+		}
+		if diffs := diffSession(l, s); len(diffs) > 0 {
+			r.Mismatched = append(r.Mismatched, sessionDiff{SessionID: l.SessionID, Fields: diffs})
+			continue
+		}
+		r.Matched++
+	}
+	for _, s := range remote {
+		if !seen[s.SessionID] {
+			r.Extra = append(r.Extra, s.SessionID)
+		}
+	}
+	slices.Sort(r.Missing)
+	slices.Sort(r.Extra)
+	slices.SortFunc(r.Mismatched, func(a, b sessionDiff) int { return strings.Compare(a.SessionID, b.SessionID) })
+	return r
+}
+
+// diffSession compares the fields the backfill is responsible for.
+func diffSession(l claug.SessionMetrics, r claug.SessionStats) []fieldDiff {
+	var diffs []fieldDiff
+	check := func(field string, local, remote any) {
+		if ls, rs := fmt.Sprint(local), fmt.Sprint(remote); ls != rs {
+			diffs = append(diffs, fieldDiff{Field: field, Local: ls, Remote: rs})
+		}
+	}
+	check("total_tokens", l.TotalTokens, r.TotalTokens)
+	check("input_tokens", l.InputTokens, r.TotalInputTokens)
+	check("cache_read_input_tokens", l.CacheReadInputTokens, r.TotalCacheReadInputTokens)
+	check("output_tokens", l.OutputTokens, r.TotalOutputTokens)
+	check("tool_calls", l.ToolCalls, r.NumToolCalls)
+	check("tool_counts", formatToolCounts(l.ToolCounts), formatToolCounts(r.ToolCounts))
+	check("user_prompts", l.UserPrompts, r.NumUserPrompts)
+	check("active_time_seconds", l.ActiveTime, r.ActiveTimeSeconds)
+	check("privacy_level", l.PrivacyLevel, r.PrivacyLevel)
+	check("model", l.Model, r.Model)
+	if l.CreatedAt != 0 {
+		check("created_at", formatUnix(l.CreatedAt), formatUnix(r.CreatedAt))
+	}
+	if l.ProviderVersion != "" {
+		check("provider_version", l.ProviderVersion, r.ProviderVersion)
+	}
+	return diffs
+}
+
+// formatToolCounts renders counts as "Bash:2 Read:3" in name order; nil and
+// empty maps both render as "{}".
+func formatToolCounts(counts map[string]int) string {
+	if len(counts) == 0 {
+		return "{}"
+	}
+	var parts []string
+	for _, name := range slices.Sorted(maps.Keys(counts)) {
+		parts = append(parts, fmt.Sprintf("%s:%d", name, counts[name]))
+	}
+	return strings.Join(parts, " ")
+}
+
+func (r reconciliation) ok(ignoreExtra bool) bool {
+	return len(r.Mismatched) == 0 && len(r.Missing) == 0 && (ignoreExtra || len(r.Extra) == 0)
+}
+
+func (r reconciliation) write(w io.Writer) {
+	fmt.Fprintf(w, "verify: %d sessions in cc-live, %d in claug\n", r.Local, r.Remote)
+	fmt.Fprintf(w, "  matched:    %d\n", r.Matched)
+	fmt.Fprintf(w, "  mismatched: %d\n", len(r.Mismatched))
+	fmt.Fprintf(w, "  missing:    %d (in cc-live, not in claug)\n", len(r.Missing))
+	fmt.Fprintf(w, "  extra:      %d (in claug, not in cc-live)\n", len(r.Extra))
+
+	for _, d := range r.Mismatched {
+		fmt.Fprintf(w, "\nMISMATCH %s\n", d.SessionID)
+		for _, f := range d.Fields {
+			fmt.Fprintf(w, "  %-24s local=%s  claug=%s\n", f.Field, f.Local, f.Remote)
+		}
+	}
+	if len(r.Missing) > 0 {
+		fmt.Fprintln(w)
+	}
+	for _, id := range r.Missing {
+		fmt.Fprintf(w, "MISSING %s\n", id)
+	}
+	if len(r.Extra) > 0 {
+		fmt.Fprintln(w)
+	}
+	for _, id := range r.Extra {
+		fmt.Fprintf(w, "EXTRA %s\n", id)
+	}
+}
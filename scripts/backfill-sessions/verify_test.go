@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/howiewang/personal-blog/scripts/claug"
+)
+
+func TestReconcile(t *testing.T) {
+	local := []claug.SessionMetrics{
+		{SessionID: "same", TotalTokens: 100, ToolCalls: 2, ToolCounts: map[string]int{"Read": 2}, PrivacyLevel: "full"},
+		{SessionID: "no-tools", TotalTokens: 50, PrivacyLevel: "full"},
+		{SessionID: "drifted", TotalTokens: 100, ActiveTime: 60, ToolCounts: map[string]int{"Bash": 1}, PrivacyLevel: "metrics_only"},
+		{SessionID: "missing", TotalTokens: 10},
+	}
+	remote := []claug.SessionStats{
+		{SessionID: "extra", TotalTokens: 5},
+		{SessionID: "drifted", TotalTokens: 100, ActiveTimeSeconds: 90, ToolCounts: map[string]int{"Bash": 1, "Read": 1}, PrivacyLevel: "full"},
+		{SessionID: "no-tools", TotalTokens: 50, ToolCounts: map[string]int{}, PrivacyLevel: "full"},
+		{SessionID: "same", TotalTokens: 100, NumToolCalls: 2, ToolCounts: map[string]int{"Read": 2}, PrivacyLevel: "full"},
+	}
+
+	r := reconcile(local, remote)
+	if r.Matched != 2 {
+		t.Errorf("Matched = %d, want 2", r.Matched)
+	}
+	if len(r.Missing) != 1 || r.Missing[0] != "missing" {
+		t.Errorf("Missing = %v", r.Missing)
+	}
+	if len(r.Extra) != 1 || r.Extra[0] != "extra" {
+		t.Errorf("Extra = %v", r.Extra)
+	}
+	if len(r.Mismatched) != 1 {
+		t.Fatalf("Mismatched = %+v, want only drifted", r.Mismatched)
+	}
+	var fields []string
+	for _, f := range r.Mismatched[0].Fields {
+		fields = append(fields, f.Field)
+	}
+	if got, want := strings.Join(fields, ","), "tool_counts,active_time_seconds,privacy_level"; got != want {
+		t.Errorf("drifted fields = %s, want %s", got, want)
+	}
+
+	if r.ok(false) || r.ok(true) {
+		t.Error("reconciliation with mismatches reported ok")
+	}
+	clean := reconcile(local[:2], remote[2:])
+	if !clean.ok(false) {
+		t.Errorf("identical sessions not ok: %+v", clean)
+	}
+	withExtra := reconcile(local[:2], append([]claug.SessionStats{remote[0]}, remote[2:]...))
+	if withExtra.ok(false) || !withExtra.ok(true) {
+		t.Error("sessions only in claug should fail unless --ignore-extra is set")
+	}
+
+	var buf bytes.Buffer
+	r.write(&buf)
+	for _, want := range []string{"MISMATCH drifted", "local=Bash:1  claug=Bash:1 Read:1", "MISSING missing", "EXTRA extra"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("report lacks %q:\n%s", want, buf.String())
+		}
+	}
+}
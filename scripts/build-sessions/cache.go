@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/howiewang/personal-blog/scripts/claug"
+)
+
+const cacheVersion = 1
+
+// sessionCache is the on-disk copy of every session fetched so far, keyed by
+// SessionID. UpdatedAt is the highest updated_at seen and becomes the
+// updated_since cursor for the next incremental fetch. Query records the
+// server-side filter the cache was filled with.
+type sessionCache struct {
+	Version   int                           `json:"version"`
+	Endpoint  string                        `json:"endpoint"`
+	Query     string                        `json:"query"`
+	UpdatedAt int64                         `json:"updated_at"`
+	Sessions  map[string]claug.SessionStats `json:"sessions"`
+}
+
+func newSessionCache(endpoint, query string) *sessionCache {
+	return &sessionCache{
+		Version:  cacheVersion,
+		Endpoint: endpoint,
+		Query:    query,
+		Sessions: make(map[string]claug.SessionStats),
+	}
+}
+
+// cachePath returns the cache file for env. Each env gets its own file, and
+// so its own updated_since cursor, next to CC_STATS_CACHE when that is set:
+// CC_STATS_CACHE=/tmp/sessions.json caches staging in /tmp/sessions.staging.json.
+func cachePath(env string) string {
+	p := os.Getenv("CC_STATS_CACHE")
+	if p == "" {
+		dir, err := os.UserCacheDir()
+		if err != nil {
+			log.Fatalf("getting cache dir: %v", err)
+		}
+		p = filepath.Join(dir, "claug", "build-sessions", "sessions.json")
+	}
+	ext := filepath.Ext(p)
+	return strings.TrimSuffix(p, ext) + "." + env + ext
+}
+
+// loadSessionCache reads the cache at path. A missing, unreadable or stale
+// cache (different format version, endpoint or query) yields an empty cache
+// so the caller falls back to a full fetch.
+func loadSessionCache(path, endpoint, query string) *sessionCache {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return newSessionCache(endpoint, query)
+	}
+	if err != nil {
+		log.Printf("reading cache %s: %v (doing a full fetch)", path, err)
+		return newSessionCache(endpoint, query)
+	}
+
+	var c sessionCache
+	if err := json.Unmarshal(data, &c); err != nil {
+		log.Printf("parsing cache %s: %v (doing a full fetch)", path, err)
+		return newSessionCache(endpoint, query)
+	}
+	if c.Version != cacheVersion || c.Endpoint != endpoint || c.Query != query {
+		log.Printf("cache %s was built with a different version, endpoint or filter (doing a full fetch)", path)
+		return newSessionCache(endpoint, query)
+	}
+	if c.Sessions == nil {
+		c.Sessions = make(map[string]claug.SessionStats)
+	}
+	return &c
+}
+
+// merge folds freshly fetched sessions into the cache, keeping whichever copy
+// of a session has the newer updated_at, and advances the cursor.
+func (c *sessionCache) merge(sessions []claug.SessionStats) (added, updated int) {
+	for _, s := range sessions {
+		old, ok := c.Sessions[s.SessionID]
+		switch {
+		case !ok:
+			added++
+		case s.UpdatedAt >= old.UpdatedAt:
+			updated++
+		default:
+			continue
+		}
+		c.Sessions[s.SessionID] = s
+		if s.UpdatedAt > c.UpdatedAt {
+			c.UpdatedAt = s.UpdatedAt
+		}
+	}
+	return added, updated
+}
+
+// list returns the cached sessions in no particular order.
+func (c *sessionCache) list() []claug.SessionStats {
+	sessions := make([]claug.SessionStats, 0, len(c.Sessions))
+	for _, s := range c.Sessions {
+		sessions = append(sessions, s)
+	}
+	return sessions
+}
+
+func (c *sessionCache) save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("creating cache directory: %w", err)
+	}
+	return writeJSONAtomic(path, c, "", nil)
+}
+
+// writeJSONAtomic encodes v and writes it with writeFileAtomic. When validate
+// is non-nil it is given the encoded JSON first, and path is left alone if it
+// fails.
+func writeJSONAtomic(path string, v any, indent string, validate func([]byte) error) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", indent)
+	if err := enc.Encode(v); err != nil {
+		return fmt.Errorf("encoding JSON: %w", err)
+	}
+	if validate != nil {
+		if err := validate(buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	return writeFileAtomic(path, buf.Bytes())
+}
+
+// writeFileAtomic writes data to a temp file next to path and renames it into
+// place, so readers never see a half-written file.
+func writeFileAtomic(path string, data []byte) error {
+	tmpFile, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("creating temp file: %w", err)
+	}
+	tmpPath := tmpFile.Name()
+	if _, err := tmpFile.Write(data); err != nil {
+		_ = tmpFile.Close()
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("writing temp file: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("closing temp file: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("renaming temp file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/howiewang/personal-blog/scripts/claug"
+)
+
+func TestSessionCacheMerge(t *testing.T) {
+	c := newSessionCache("https://api.example", "from=x")
+	added, updated := c.merge([]claug.SessionStats{
+		{SessionID: "a", UpdatedAt: 10, TotalTokens: 1},
+		{SessionID: "b", UpdatedAt: 20, TotalTokens: 1},
+	})
+	if added != 2 || updated != 0 || c.UpdatedAt != 20 {
+		t.Fatalf("first merge: added=%d updated=%d cursor=%d", added, updated, c.UpdatedAt)
+	}
+
+	added, updated = c.merge([]claug.SessionStats{
+		{SessionID: "a", UpdatedAt: 30, TotalTokens: 2},
+		{SessionID: "b", UpdatedAt: 5, TotalTokens: 99}, // stale copy
+	})
+	if added != 0 || updated != 1 || c.UpdatedAt != 30 {
+		t.Errorf("second merge: added=%d updated=%d cursor=%d", added, updated, c.UpdatedAt)
+	}
+	if c.Sessions["a"].TotalTokens != 2 || c.Sessions["b"].TotalTokens != 1 {
+		t.Errorf("merge kept the wrong copies: %+v", c.Sessions)
+	}
+}
+
+func TestSessionCacheRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "sessions.json")
+	c := newSessionCache("https://api.example", "from=x")
+	c.merge([]claug.SessionStats{{SessionID: "a", UpdatedAt: 10}})
+	if err := c.save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := loadSessionCache(path, "https://api.example", "from=x"); got.UpdatedAt != 10 || len(got.Sessions) != 1 {
+		t.Errorf("reloaded cache = %+v", got)
+	}
+	// A different endpoint or query starts over.
+	if got := loadSessionCache(path, "https://other.example", "from=x"); len(got.Sessions) != 0 {
+		t.Error("cache reused across endpoints")
+	}
+	if got := loadSessionCache(path, "https://api.example", "from=y"); len(got.Sessions) != 0 {
+		t.Error("cache reused across queries")
+	}
+
+	if err := os.WriteFile(path, []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := loadSessionCache(path, "https://api.example", "from=x"); len(got.Sessions) != 0 {
+		t.Error("corrupt cache was not discarded")
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/howiewang/personal-blog/scripts/claug"
+)
+
+// chartsFormat writes the chart datasets under --charts-dir.
+const chartsFormat = "charts"
+
+// chartToolCount is how many tools tool_mix.csv names before lumping the
+// rest into "other", so a stack fits the seven colors of the timberline
+// palette.
+const chartToolCount = 6
+
+// otherTool is the tool_mix.csv row for every tool outside the top ones.
+const otherTool = "other"
+
+// lengthBuckets are the session_length.csv bins by active time. Each includes
+// its lower bound; the last is open-ended.
+var lengthBuckets = []struct {
+	label   string
+	minSecs int
+	maxSecs int // exclusive; 0 means no upper bound
+}{
+	{"<5m", 0, 5 * 60},
+	{"5-15m", 5 * 60, 15 * 60},
+	{"15-30m", 15 * 60, 30 * 60},
+	{"30m-1h", 30 * 60, 60 * 60},
+	{"1-2h", 60 * 60, 2 * 60 * 60},
+	{"2-4h", 2 * 60 * 60, 4 * 60 * 60},
+	{"4h+", 4 * 60 * 60, 0},
+}
+
+// chartData holds the tidy, chart-ready tables written by --format charts.
+// Their columns are a contract with the scripts in scripts/plots; add
+// columns at the end rather than renaming or reordering.
+type chartData struct {
+	TokensPerDay  [][]string
+	ToolMix       [][]string
+	SessionLength [][]string
+}
+
+// buildCharts derives the chart datasets from the same sessions, filters and
+// privacy rules as data, reusing its daily and weekly rollups.
+func buildCharts(sessions []claug.SessionStats, opts exportOptions, data dataExport) chartData {
+	return chartData{
+		TokensPerDay:  tokensPerDay(data.Rollups.Daily),
+		ToolMix:       toolMix(sessions, opts, data.Rollups.Weekly),
+		SessionLength: sessionLength(sessions, opts),
+	}
+}
+
+// tokensPerDay has one row per day, including days without sessions.
+func tokensPerDay(daily []rollupBucket) [][]string {
+	rows := [][]string{{
+		"date", "sessions", "input_tokens", "cache_read_input_tokens", "output_tokens",
+		"total_tokens", "tool_calls", "active_time_seconds", "estimated_cost_usd",
+	}}
+	for _, b := range daily {
+		rows = append(rows, []string{
+			b.Period,
+			strconv.Itoa(b.SessionCount),
+			strconv.FormatInt(b.TotalInputTokens, 10),
+			strconv.FormatInt(b.TotalCacheReadInputTokens, 10),
+			strconv.FormatInt(b.TotalOutputTokens, 10),
+			strconv.FormatInt(b.TotalTokens, 10),
+			strconv.Itoa(b.TotalToolCalls),
+			strconv.Itoa(b.TotalActiveTimeSeconds),
+			strconv.FormatFloat(b.EstimatedCostUSD, 'f', -1, 64),
+		})
+	}
+	return rows
+}
+
+// toolMix has a row for every ISO week and every top tool (plus other),
+// zero-filled, with the tool's share of that week's calls. Only sessions
+// whose privacy rule shows tools count, as for top_tools.
+func toolMix(sessions []claug.SessionStats, opts exportOptions, weeks []rollupBucket) [][]string {
+	byWeek := make(map[string]map[string]int)
+	names := make(map[string]bool)
+	var all []map[string]int
+	for _, s := range sessions {
+		if !opts.Filter.match(s) || !privacyRuleFor(s.PrivacyLevel).ShowTools || s.CreatedAt == 0 || len(s.ToolCounts) == 0 {
+			continue
+		}
+		week := weekly.label(weekly.start(time.Unix(s.CreatedAt, 0).In(opts.Location)))
+		if byWeek[week] == nil {
+			byWeek[week] = make(map[string]int)
+		}
+		for name, n := range s.ToolCounts {
+			byWeek[week][name] += n
+			names[name] = true
+		}
+		all = append(all, s.ToolCounts)
+	}
+
+	top := claug.TopTools(all, chartToolCount)
+	isTop := make(map[string]bool, len(top))
+	for _, t := range top {
+		isTop[t.Name] = true
+	}
+	tools := append([]claug.ToolCount{}, top...)
+	if len(names) > len(top) {
+		tools = append(tools, claug.ToolCount{Name: otherTool, Display: otherTool})
+	}
+
+	rows := [][]string{{"week", "week_start", "tool", "tool_display", "calls", "share"}}
+	for _, w := range weeks {
+		counts := byWeek[w.Period]
+		total, other := 0, 0
+		for name, n := range counts {
+			total += n
+			if !isTop[name] {
+				other += n
+			}
+		}
+		for _, t := range tools {
+			calls := counts[t.Name]
+			if t.Name == otherTool {
+				calls = other
+			}
+			share := 0.0
+			if total > 0 {
+				share = float64(calls) / float64(total)
+			}
+			rows = append(rows, []string{
+				w.Period, w.Start[:len("2006-01-02")], t.Name, t.Display,
+				strconv.Itoa(calls), strconv.FormatFloat(share, 'f', 4, 64),
+			})
+		}
+	}
+	return rows
+}
+
+// sessionLength counts sessions per lengthBuckets bin of active time.
+func sessionLength(sessions []claug.SessionStats, opts exportOptions) [][]string {
+	counts := make([]int, len(lengthBuckets))
+	total := 0
+	for _, s := range sessions {
+		if !opts.Filter.match(s) {
+			continue
+		}
+		total++
+		for i := len(lengthBuckets) - 1; i >= 0; i-- {
+			if s.ActiveTimeSeconds >= lengthBuckets[i].minSecs {
+				counts[i]++
+				break
+			}
+		}
+	}
+
+	rows := [][]string{{"bucket", "min_seconds", "max_seconds", "sessions", "share"}}
+	for i, b := range lengthBuckets {
+		maxSecs := ""
+		if b.maxSecs > 0 {
+			maxSecs = strconv.Itoa(b.maxSecs)
+		}
+		share := 0.0
+		if total > 0 {
+			share = float64(counts[i]) / float64(total)
+		}
+		rows = append(rows, []string{
+			b.label, strconv.Itoa(b.minSecs), maxSecs,
+			strconv.Itoa(counts[i]), strconv.FormatFloat(share, 'f', 4, 64),
+		})
+	}
+	return rows
+}
+
+// writeCharts writes each dataset as a CSV file in dir.
+func writeCharts(dir string, charts chartData) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("creating charts directory: %w", err)
+	}
+	for _, f := range []struct {
+		name string
+		rows [][]string
+	}{
+		{"tokens_per_day.csv", charts.TokensPerDay},
+		{"tool_mix.csv", charts.ToolMix},
+		{"session_length.csv", charts.SessionLength},
+	} {
+		var buf bytes.Buffer
+		cw := csv.NewWriter(&buf)
+		if err := cw.WriteAll(f.rows); err != nil {
+			return fmt.Errorf("encoding %s: %w", f.name, err)
+		}
+		path := filepath.Join(dir, f.name)
+		if err := writeFileAtomic(path, buf.Bytes()); err != nil {
+			return fmt.Errorf("writing %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// chartsPath returns CC_STATS_CHARTS_DIR, or scripts/plots/data relative to
+// the working directory (scripts/build-sessions -> scripts/plots/data).
+func chartsPath() string {
+	if dir := os.Getenv("CC_STATS_CHARTS_DIR"); dir != "" {
+		return dir
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		log.Fatalf("getting working directory: %v", err)
+	}
+	return filepath.Join(wd, "..", "plots", "data")
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/howiewang/personal-blog/scripts/claug"
+)
+
+// TestChartsGolden pins the chart datasets' columns and values; the plot
+// scripts in scripts/plots read them by column name.
+func TestChartsGolden(t *testing.T) {
+	sessions := loadTestSessions(t)
+	opts := testExportOptions(t)
+	dir := t.TempDir()
+	if err := writeCharts(dir, buildCharts(sessions, opts, buildExport(sessions, opts))); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"tokens_per_day.csv", "tool_mix.csv", "session_length.csv"} {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkGolden(t, filepath.Join("charts", name), got)
+	}
+}
+
+func TestToolMixRespectsPrivacy(t *testing.T) {
+	created := time.Date(2026, 3, 4, 12, 0, 0, 0, time.UTC).Unix() // ISO week 2026-W10
+	sessions := []claug.SessionStats{
+		{SessionID: "a", CreatedAt: created, TotalTokens: 10, PrivacyLevel: "full", ToolCounts: map[string]int{"Read": 3, "Bash": 1}},
+		{SessionID: "b", CreatedAt: created, TotalTokens: 10, PrivacyLevel: "metrics_only", ToolCounts: map[string]int{"SecretTool": 50}},
+	}
+	opts := testExportOptions(t)
+	rows := toolMix(sessions, opts, buildExport(sessions, opts).Rollups.Weekly)
+	want := [][]string{
+		{"week", "week_start", "tool", "tool_display", "calls", "share"},
+		{"2026-W10", "2026-03-02", "Read", "Read", "3", "0.7500"},
+		{"2026-W10", "2026-03-02", "Bash", "Bash", "1", "0.2500"},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("toolMix = %v, want %v", rows, want)
+	}
+	for i := range want {
+		for j := range want[i] {
+			if rows[i][j] != want[i][j] {
+				t.Errorf("row %d = %v, want %v", i, rows[i], want[i])
+				break
+			}
+		}
+	}
+}
+
+func TestToolMixLumpsOtherTools(t *testing.T) {
+	created := time.Date(2026, 3, 4, 12, 0, 0, 0, time.UTC).Unix()
+	counts := make(map[string]int)
+	for i := range chartToolCount + 2 {
+		counts["tool"+strconv.Itoa(i)] = 10 - i
+	}
+	sessions := []claug.SessionStats{{SessionID: "a", CreatedAt: created, TotalTokens: 10, ToolCounts: counts}}
+	opts := testExportOptions(t)
+	rows := toolMix(sessions, opts, buildExport(sessions, opts).Rollups.Weekly)
+	if len(rows) != 1+chartToolCount+1 {
+		t.Fatalf("got %d rows, want header + %d tools + other", len(rows), chartToolCount)
+	}
+	if last := rows[len(rows)-1]; last[2] != otherTool || last[4] != strconv.Itoa(10-chartToolCount+10-chartToolCount-1) {
+		t.Errorf("other row = %v", last)
+	}
+}
+
+func TestSessionLengthBuckets(t *testing.T) {
+	created := time.Date(2026, 3, 4, 12, 0, 0, 0, time.UTC).Unix()
+	var sessions []claug.SessionStats
+	for i, secs := range []int{0, 299, 300, 3599, 3600, 4 * 3600, 50 * 3600} {
+		sessions = append(sessions, claug.SessionStats{SessionID: strconv.Itoa(i), CreatedAt: created, TotalTokens: 1, ActiveTimeSeconds: secs})
+	}
+	rows := sessionLength(sessions, testExportOptions(t))
+	want := map[string]string{"<5m": "2", "5-15m": "1", "15-30m": "0", "30m-1h": "1", "1-2h": "1", "2-4h": "0", "4h+": "2"}
+	for _, row := range rows[1:] {
+		if row[3] != want[row[0]] {
+			t.Errorf("bucket %s has %s sessions, want %s", row[0], row[3], want[row[0]])
+		}
+	}
+	if last := rows[len(rows)-1]; last[2] != "" {
+		t.Errorf("open bucket max_seconds = %q, want empty", last[2])
+	}
+}
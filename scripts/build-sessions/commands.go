@@ -0,0 +1,252 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/howiewang/personal-blog/scripts/claug"
+)
+
+// fetchFlags are shared by every command that talks to the claug API.
+type fetchFlags struct {
+	retry claug.RetryPolicy
+}
+
+func registerFetchFlags(fs *flag.FlagSet) *fetchFlags {
+	f := &fetchFlags{retry: claug.DefaultRetryPolicy}
+	fs.IntVar(&f.retry.Budget, "retry-budget", int(envInt64("CC_STATS_RETRY_BUDGET", int64(f.retry.Budget))), "total API retries allowed per env per run [CC_STATS_RETRY_BUDGET]")
+	fs.IntVar(&f.retry.MaxAttempts, "max-attempts", int(envInt64("CC_STATS_MAX_ATTEMPTS", int64(f.retry.MaxAttempts))), "attempts per API request, including the first [CC_STATS_MAX_ATTEMPTS]")
+	return f
+}
+
+// registerFullFlag adds --full for commands that sync through the local cache.
+func registerFullFlag(fs *flag.FlagSet) *bool {
+	return fs.Bool("full", false, "ignore the local cache and refetch every session")
+}
+
+// renderFlags are shared by every command that builds an export.
+type renderFlags struct {
+	tz          string
+	projectSort string
+	pricing     string
+}
+
+func registerRenderFlags(fs *flag.FlagSet) *renderFlags {
+	r := &renderFlags{}
+	fs.StringVar(&r.tz, "tz", envOr("CC_STATS_TZ", "UTC"), "IANA time zone for session dates and rollups [CC_STATS_TZ]")
+	fs.StringVar(&r.projectSort, "project-sort", envOr("CC_STATS_PROJECT_SORT", "tokens"), "metric to rank projects by: tokens, sessions, active_time, tool_calls, cost or last_session [CC_STATS_PROJECT_SORT]")
+	fs.StringVar(&r.pricing, "pricing", os.Getenv("CC_STATS_PRICING"), "YAML or JSON pricing table for cost estimates (default ./"+defaultPricingFile+" if present) [CC_STATS_PRICING]")
+	return r
+}
+
+// options validates the render flags and combines them with filter.
+func (r *renderFlags) options(filter sessionFilter) (exportOptions, error) {
+	if err := validProjectSort(r.projectSort); err != nil {
+		return exportOptions{}, fmt.Errorf("invalid --project-sort: %w", err)
+	}
+	loc, err := time.LoadLocation(r.tz)
+	if err != nil {
+		return exportOptions{}, fmt.Errorf("invalid --tz: %w", err)
+	}
+	pricing, err := loadPricing(r.pricing)
+	if err != nil {
+		return exportOptions{}, fmt.Errorf("loading pricing: %w", err)
+	}
+	return exportOptions{
+		Filter:      filter,
+		Location:    loc,
+		Pricing:     pricing,
+		ProjectSort: r.projectSort,
+	}, nil
+}
+
+func runSync(fs *flag.FlagSet, args []string) error {
+	ff := registerFetchFlags(fs)
+	full := registerFullFlag(fs)
+	filterOpts := registerFilterFlags(fs)
+	rf := registerRenderFlags(fs)
+	outputs := registerOutputFlags(fs)
+	fs.Parse(args)
+
+	filter, err := filterOpts.parse()
+	if err != nil {
+		return fmt.Errorf("invalid filter: %w", err)
+	}
+	opts, err := rf.options(filter)
+	if err != nil {
+		return err
+	}
+	if err := outputs.validate(); err != nil {
+		return err
+	}
+	sessions, err := fetchAll(filter, ff.retry, *full)
+	if err != nil {
+		return err
+	}
+	return outputs.write(sessions, opts)
+}
+
+func runFetch(fs *flag.FlagSet, args []string) error {
+	ff := registerFetchFlags(fs)
+	filterOpts := registerFilterFlags(fs)
+	out := fs.String("snapshot", snapshotPath(), "snapshot file to write (- for stdout) [CC_STATS_SNAPSHOT]")
+	fs.Parse(args)
+
+	filter, err := filterOpts.parse()
+	if err != nil {
+		return fmt.Errorf("invalid filter: %w", err)
+	}
+	snap, err := fetchSnapshot(filter, ff.retry)
+	if err != nil {
+		return err
+	}
+	if err := writeSnapshot(*out, snap); err != nil {
+		return err
+	}
+	log.Printf("saved snapshot of %d sessions from %s to %s", len(snap.sessions()), strings.Join(snap.envNames(), ", "), *out)
+	return nil
+}
+
+func runRender(fs *flag.FlagSet, args []string) error {
+	sf := registerSnapshotFlags(fs, snapshotPath(), "snapshot file written by fetch (- for stdin) [CC_STATS_SNAPSHOT]")
+	filterOpts := registerFilterFlags(fs)
+	rf := registerRenderFlags(fs)
+	outputs := registerOutputFlags(fs)
+	fs.Parse(args)
+
+	filter, err := filterOpts.parse()
+	if err != nil {
+		return fmt.Errorf("invalid filter: %w", err)
+	}
+	opts, err := rf.options(filter)
+	if err != nil {
+		return err
+	}
+	if err := outputs.validate(); err != nil {
+		return err
+	}
+	snap, err := sf.read()
+	if err != nil {
+		return err
+	}
+	return outputs.write(snap.sessions(), opts)
+}
+
+// selectedEnvs returns the claug config dir and the active envs selected by
+// filter.
+func selectedEnvs(filter sessionFilter) (dir string, envs []string, err error) {
+	dir, err = claug.ConfigDir()
+	if err != nil {
+		return "", nil, fmt.Errorf("loading claug config: %w", err)
+	}
+	active, err := claug.ActiveEnvs(dir)
+	if err != nil {
+		return "", nil, fmt.Errorf("loading claug config: %w", err)
+	}
+	envs, err = selectEnvs(active, filter.Envs)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid filter: %w", err)
+	}
+	return dir, envs, nil
+}
+
+// fetchAll syncs each active env selected by filter through its cache and
+// returns their merged sessions.
+func fetchAll(filter sessionFilter, retry claug.RetryPolicy, full bool) ([]claug.SessionStats, error) {
+	dir, envs, err := selectedEnvs(filter)
+	if err != nil {
+		return nil, err
+	}
+	perEnv := make([][]claug.SessionStats, 0, len(envs))
+	for _, env := range envs {
+		sessions, err := syncEnv(dir, env, filter, retry, full)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", env, err)
+		}
+		perEnv = append(perEnv, sessions)
+	}
+	sessions := mergeEnvs(perEnv)
+	if len(envs) > 1 {
+		log.Printf("merged %d sessions from %d envs (%s)", len(sessions), len(envs), strings.Join(envs, ", "))
+	}
+	return sessions, nil
+}
+
+// fetchSnapshot fetches every page from each active env selected by filter,
+// bypassing the cache so the snapshot stands on its own, and redacts it.
+func fetchSnapshot(filter sessionFilter, retry claug.RetryPolicy) (snapshot, error) {
+	dir, envs, err := selectedEnvs(filter)
+	if err != nil {
+		return snapshot{}, err
+	}
+	q := url.Values{}
+	filter.apply(q)
+	snap := snapshot{
+		Version:   snapshotVersion,
+		FetchedAt: time.Now().Unix(),
+		Query:     q.Encode(),
+	}
+	for _, env := range envs {
+		cfg, err := claug.LoadEnv(dir, env)
+		if err != nil {
+			return snapshot{}, fmt.Errorf("%s: loading claug config: %w", env, err)
+		}
+		pages, err := claug.NewClient(cfg, retry).ListSessionsPages(q)
+		if err != nil {
+			return snapshot{}, fmt.Errorf("%s: %w", env, err)
+		}
+		snap.Envs = append(snap.Envs, snapshotEnv{Env: env, Endpoint: cfg.Endpoint, Pages: pages})
+	}
+	snap.redact()
+	return snap, nil
+}
+
+// syncEnv brings env's session cache up to date and returns its sessions,
+// each tagged with env.
+func syncEnv(dir, env string, filter sessionFilter, retry claug.RetryPolicy, full bool) ([]claug.SessionStats, error) {
+	cfg, err := claug.LoadEnv(dir, env)
+	if err != nil {
+		return nil, fmt.Errorf("loading claug config: %w", err)
+	}
+
+	cacheFile := cachePath(env)
+	cache := newSessionCache(cfg.Endpoint, filter.query())
+	if !full {
+		cache = loadSessionCache(cacheFile, cfg.Endpoint, filter.query())
+	}
+
+	fetched, err := fetchSessions(claug.NewClient(cfg, retry), filter, cache.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	added, updated := cache.merge(fetched)
+	log.Printf("%s: fetched %d sessions from %s (%d new, %d updated, %d cached)", env, len(fetched), cfg.Endpoint, added, updated, len(cache.Sessions))
+
+	if err := cache.save(cacheFile); err != nil {
+		log.Printf("WARNING: saving cache %s: %v", cacheFile, err)
+	}
+
+	sessions := cache.list()
+	for i := range sessions {
+		sessions[i].Env = env
+	}
+	return sessions, nil
+}
+
+// fetchSessions fetches every session matching the server-side part of
+// filter. When updatedSince is non-zero only sessions updated at or after that
+// unix timestamp are requested.
+func fetchSessions(client *claug.Client, filter sessionFilter, updatedSince int64) ([]claug.SessionStats, error) {
+	q := url.Values{}
+	filter.apply(q)
+	if updatedSince > 0 {
+		q.Set("updated_since", strconv.FormatInt(updatedSince, 10))
+	}
+	return client.ListSessions(q)
+}
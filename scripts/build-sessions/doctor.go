@@ -0,0 +1,223 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/howiewang/personal-blog/scripts/claug"
+)
+
+// doctorReport prints one line per check and remembers whether any failed.
+type doctorReport struct {
+	w      io.Writer
+	failed int
+}
+
+func (r *doctorReport) ok(name, detail string) {
+	fmt.Fprintf(r.w, "ok    %s: %s\n", name, detail)
+}
+
+func (r *doctorReport) warn(name, detail string) {
+	fmt.Fprintf(r.w, "warn  %s: %s\n", name, detail)
+}
+
+func (r *doctorReport) fail(name string, err error) {
+	r.failed++
+	fmt.Fprintf(r.w, "FAIL  %s: %v\n", name, err)
+}
+
+// runDoctor takes the same flags as sync and checks everything sync would
+// use, asking each env's API for a single page instead of syncing.
+func runDoctor(fset *flag.FlagSet, args []string) error {
+	ff := registerFetchFlags(fset)
+	filterOpts := registerFilterFlags(fset)
+	rf := registerRenderFlags(fset)
+	outputs := registerOutputFlags(fset)
+	sf := registerSnapshotFlags(fset, snapshotPath(), "snapshot file render would read [CC_STATS_SNAPSHOT]")
+	fset.Parse(args)
+
+	r := &doctorReport{w: os.Stdout}
+	filter, err := filterOpts.parse()
+	if err != nil {
+		r.fail("filters", err)
+	} else {
+		r.ok("filters", filterSummary(filter))
+	}
+	if _, err := rf.options(filter); err != nil {
+		r.fail("render flags", err)
+	} else {
+		r.ok("render flags", fmt.Sprintf("tz %s, projects by %s, pricing %s", rf.tz, rf.projectSort, pricingSummary(rf.pricing)))
+	}
+	checkEnvs(r, filter, ff.retry)
+	if err := outputs.validate(); err != nil {
+		r.fail("export", err)
+	} else {
+		for _, f := range outputs.formats {
+			switch f {
+			case hugoFormat:
+				checkExportDir(r, outputs.out)
+			case chartsFormat:
+				checkExportDir(r, filepath.Join(outputs.chartsDir, "tokens_per_day.csv"))
+			case svgFormat:
+				checkExportDir(r, filepath.Join(outputs.svgDir, sparklineFile))
+			default:
+				checkExportDir(r, filepath.Join(outputs.exportDir, exporters[f].file))
+			}
+		}
+	}
+	checkSnapshot(r, sf, time.Now())
+
+	if r.failed > 0 {
+		return fmt.Errorf("%d checks failed", r.failed)
+	}
+	return nil
+}
+
+// checkEnvs checks the claug config dir, then the credentials, API access and
+// cache of every env sync would read.
+func checkEnvs(r *doctorReport, filter sessionFilter, retry claug.RetryPolicy) {
+	dir, err := claug.ConfigDir()
+	if err != nil {
+		r.fail("claug config", err)
+		return
+	}
+	if _, err := os.Stat(dir); err != nil {
+		r.fail("claug config", err)
+		return
+	}
+	active, err := claug.ActiveEnvs(dir)
+	if err != nil {
+		r.fail("claug config", err)
+		return
+	}
+	envs, err := selectEnvs(active, filter.Envs)
+	if err != nil {
+		r.fail("claug config", err)
+		return
+	}
+	r.ok("claug config", fmt.Sprintf("%s, envs %s", dir, strings.Join(envs, ", ")))
+
+	q := url.Values{}
+	filter.apply(q)
+	for _, env := range envs {
+		name := "env " + env
+		cfg, err := claug.LoadEnv(dir, env)
+		if err != nil {
+			r.fail(name, err)
+			continue
+		}
+		page, err := claug.NewClient(cfg, retry).ListSessionsPage(q, 1, 1)
+		var ae *claug.AuthError
+		switch {
+		case errors.As(err, &ae):
+			r.fail(name, fmt.Errorf("%s rejected the api_key for %q; run 'claug login': %w", cfg.Endpoint, env, err))
+			continue
+		case err != nil:
+			r.fail(name, err)
+			continue
+		}
+		r.ok(name, fmt.Sprintf("%s, %d sessions match the filters", cfg.Endpoint, page.Total))
+
+		cacheFile := cachePath(env)
+		if _, err := os.Stat(cacheFile); errors.Is(err, fs.ErrNotExist) {
+			r.warn(name+" cache", cacheFile+" does not exist yet; the next sync fetches everything")
+		} else if err != nil {
+			r.fail(name+" cache", err)
+		} else {
+			r.ok(name+" cache", cacheFile)
+		}
+	}
+}
+
+// checkExportDir checks that the export's directory, or the nearest existing
+// parent writeExport would create it under, is a directory, and that any
+// existing export isn't from a newer schema version.
+func checkExportDir(r *doctorReport, out string) {
+	if err := checkSchemaVersion(out); err != nil {
+		r.fail("export", err)
+		return
+	}
+	dir := filepath.Dir(out)
+	for {
+		info, err := os.Stat(dir)
+		if err == nil {
+			if !info.IsDir() {
+				r.fail("export", fmt.Errorf("%s is not a directory", dir))
+			} else {
+				r.ok("export", out)
+			}
+			return
+		}
+		if !errors.Is(err, fs.ErrNotExist) || filepath.Dir(dir) == dir {
+			r.fail("export", err)
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// checkSnapshot reports the snapshot's age. A missing or stale snapshot is
+// only a warning, since sync doesn't need one.
+func checkSnapshot(r *doctorReport, sf *snapshotFlags, now time.Time) {
+	if _, err := os.Stat(sf.path); errors.Is(err, fs.ErrNotExist) {
+		r.warn("snapshot", sf.path+" does not exist; run fetch before rendering offline")
+		return
+	}
+	snap, err := readSnapshot(sf.path)
+	if err != nil {
+		r.fail("snapshot", err)
+		return
+	}
+	if warning := snap.staleWarning(now, sf.maxAge); warning != "" {
+		r.warn("snapshot", sf.path+": "+warning)
+		return
+	}
+	r.ok("snapshot", fmt.Sprintf("%s, %d sessions from %s, fetched %s", sf.path, len(snap.sessions()),
+		strings.Join(snap.envNames(), ", "), time.Unix(snap.FetchedAt, 0).UTC().Format(time.RFC3339)))
+}
+
+func filterSummary(f sessionFilter) string {
+	var parts []string
+	if !f.From.IsZero() {
+		parts = append(parts, "from "+f.From.Format("2006-01-02"))
+	}
+	if !f.To.IsZero() {
+		parts = append(parts, "to "+f.To.Format("2006-01-02"))
+	}
+	for _, l := range []struct {
+		name     string
+		patterns []string
+	}{
+		{"projects", f.Projects},
+		{"excluding", f.ExcludeProjects},
+		{"models", f.Models},
+		{"providers", f.Providers},
+		{"envs", f.Envs},
+	} {
+		if len(l.patterns) > 0 {
+			parts = append(parts, l.name+" "+strings.Join(l.patterns, ","))
+		}
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, ", ")
+}
+
+func pricingSummary(file string) string {
+	if file != "" {
+		return file
+	}
+	if _, err := os.Stat(defaultPricingFile); err == nil {
+		return defaultPricingFile
+	}
+	return "none"
+}
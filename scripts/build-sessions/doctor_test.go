@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/howiewang/personal-blog/scripts/claug"
+	"github.com/howiewang/personal-blog/scripts/claug/claugtest"
+)
+
+func TestDoctorChecksEveryEnv(t *testing.T) {
+	srv := claugtest.NewServer(loadTestSessions(t)...)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	config := fmt.Sprintf("envs:\n  prod:\n    endpoint: %s\n  staging:\n    endpoint: %s\nactive: [prod, staging, dev]\n", srv.URL, srv.URL)
+	auth := fmt.Sprintf(`{"version":"1","credentials":{"prod":{"api_key":%q},"staging":{"api_key":"wrong"}}}`, claugtest.APIKey)
+	for name, data := range map[string]string{"config.yaml": config, "auth.json": auth} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("CLAUG_CONFIG_DIR", dir)
+	t.Setenv("CLAUG_ENV", "")
+	t.Setenv("CC_STATS_CACHE", filepath.Join(t.TempDir(), "sessions.json"))
+
+	var buf bytes.Buffer
+	r := &doctorReport{w: &buf}
+	checkEnvs(r, sessionFilter{}, claug.RetryPolicy{Budget: 0, MaxAttempts: 1})
+	got := buf.String()
+
+	for _, want := range []string{
+		"ok    claug config: " + dir + ", envs prod, staging, dev",
+		"ok    env prod: " + srv.URL,
+		"warn  env prod cache: ",
+		"FAIL  env staging: " + srv.URL + " rejected the api_key",
+		`FAIL  env dev: no api_key found for env "dev"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("report missing %q:\n%s", want, got)
+		}
+	}
+	if r.failed != 2 {
+		t.Errorf("failed = %d, want 2", r.failed)
+	}
+}
+
+func TestCheckExportDir(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "file")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	newer := filepath.Join(root, "newer.json")
+	if err := os.WriteFile(newer, []byte(`{"schema_version": 99}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		out    string
+		failed int
+	}{
+		{filepath.Join(root, "data", "cc_sessions.json"), 0}, // created on write
+		{filepath.Join(file, "data", "cc_sessions.json"), 1},
+		{newer, 1},
+	}
+	for _, tt := range tests {
+		r := &doctorReport{w: &bytes.Buffer{}}
+		checkExportDir(r, tt.out)
+		if r.failed != tt.failed {
+			t.Errorf("checkExportDir(%s) failed %d checks, want %d", tt.out, r.failed, tt.failed)
+		}
+	}
+}
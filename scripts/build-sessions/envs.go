@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/howiewang/personal-blog/scripts/claug"
+)
+
+// selectEnvs keeps the active envs named in only (all of them when only is
+// empty), preserving the active order.
+func selectEnvs(active, only []string) ([]string, error) {
+	if len(only) == 0 {
+		return active, nil
+	}
+	var envs []string
+	for _, env := range active {
+		if claug.MatchAny(only, env) {
+			envs = append(envs, env)
+		}
+	}
+	if len(envs) == 0 {
+		return nil, fmt.Errorf("--env %s matches none of the active envs (%s)",
+			strings.Join(only, ","), strings.Join(active, ", "))
+	}
+	return envs, nil
+}
+
+// mergeEnvs combines the sessions fetched from each env, given in active
+// order. A session present in several envs is kept once: the copy with the
+// highest updated_at, or the one from the earliest env on a tie.
+func mergeEnvs(perEnv [][]claug.SessionStats) []claug.SessionStats {
+	byID := make(map[string]int)
+	var merged []claug.SessionStats
+	for _, sessions := range perEnv {
+		for _, s := range sessions {
+			i, ok := byID[s.SessionID]
+			if !ok {
+				byID[s.SessionID] = len(merged)
+				merged = append(merged, s)
+				continue
+			}
+			if s.UpdatedAt > merged[i].UpdatedAt {
+				merged[i] = s
+			}
+		}
+	}
+	return merged
+}
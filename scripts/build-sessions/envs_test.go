@@ -0,0 +1,57 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/howiewang/personal-blog/scripts/claug"
+)
+
+func TestSelectEnvs(t *testing.T) {
+	active := []string{"prod", "staging", "stage-2"}
+	tests := []struct {
+		only []string
+		want []string
+	}{
+		{nil, active},
+		{[]string{"stag*"}, []string{"staging", "stage-2"}},
+		{[]string{"stage-2", "prod"}, []string{"prod", "stage-2"}},
+	}
+	for _, tt := range tests {
+		got, err := selectEnvs(active, tt.only)
+		if err != nil {
+			t.Fatalf("selectEnvs(%v): %v", tt.only, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("selectEnvs(%v) = %v, want %v", tt.only, got, tt.want)
+		}
+	}
+	if _, err := selectEnvs(active, []string{"dev"}); err == nil {
+		t.Error("selectEnvs(dev) succeeded, want error")
+	}
+}
+
+func TestMergeEnvs(t *testing.T) {
+	prod := []claug.SessionStats{
+		{SessionID: "a", UpdatedAt: 100, Env: "prod"},
+		{SessionID: "b", UpdatedAt: 100, Env: "prod"},
+		{SessionID: "c", UpdatedAt: 100, Env: "prod"},
+	}
+	staging := []claug.SessionStats{
+		{SessionID: "a", UpdatedAt: 200, Env: "staging"}, // newer: wins
+		{SessionID: "b", UpdatedAt: 100, Env: "staging"}, // tie: prod wins
+		{SessionID: "c", UpdatedAt: 50, Env: "staging"},  // older: prod wins
+		{SessionID: "d", UpdatedAt: 10, Env: "staging"},
+	}
+	got := make(map[string]string)
+	for _, s := range mergeEnvs([][]claug.SessionStats{prod, staging}) {
+		if _, dup := got[s.SessionID]; dup {
+			t.Errorf("session %s merged twice", s.SessionID)
+		}
+		got[s.SessionID] = s.Env
+	}
+	want := map[string]string{"a": "staging", "b": "prod", "c": "prod", "d": "staging"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeEnvs envs = %v, want %v", got, want)
+	}
+}
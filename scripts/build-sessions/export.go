@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/howiewang/personal-blog/scripts/claug"
+)
+
+// Export types — match cc_sessions.schema.json, the contract with Hugo's
+// cc-sessions shortcode. writeExport validates against it.
+type sessionExport struct {
+	SessionID                   string  `json:"session_id"`
+	Date                        string  `json:"date"`
+	DateDisplay                 string  `json:"date_display"`
+	Summary                     string  `json:"summary"`
+	Model                       string  `json:"model"`
+	Project                     string  `json:"project"`
+	Env                         string  `json:"env"`
+	Cwd                         string  `json:"cwd"`
+	NumUserPrompts              int     `json:"num_user_prompts"`
+	NumToolCalls                int     `json:"num_tool_calls"`
+	TotalInputTokens            int64   `json:"total_input_tokens"`
+	TotalCacheReadInputTokens   int64   `json:"total_cache_read_input_tokens"`
+	TotalCacheReadTokensDisplay string  `json:"total_cache_read_tokens_display"`
+	TotalOutputTokens           int64   `json:"total_output_tokens"`
+	TotalTokens                 int64   `json:"total_tokens"`
+	TotalTokensDisplay          string  `json:"total_tokens_display"`
+	TotalTokensDisplayShort     string  `json:"total_tokens_display_short"`
+	ActiveTimeSeconds           int     `json:"active_time_seconds"`
+	ActiveTimeDisplay           string  `json:"active_time_display"`
+	CcVersion                   string  `json:"cc_version"`
+	Redacted                    bool    `json:"redacted"`
+	EstimatedCostUSD            float64 `json:"estimated_cost_usd,omitempty"`
+	EstimatedCostDisplay        string  `json:"estimated_cost_display,omitempty"`
+	CostUnpriced                bool    `json:"cost_unpriced,omitempty"`
+}
+
+type dataExport struct {
+	SchemaVersion int             `json:"schema_version"`
+	Sessions      []sessionExport `json:"sessions"`
+	Totals        claug.Totals    `json:"totals"`
+	Rollups       rollupsExport   `json:"rollups"`
+	Projects      []projectExport `json:"projects"`
+}
+
+// exportOptions controls how buildExport turns sessions into dataExport.
+type exportOptions struct {
+	Filter      sessionFilter
+	Location    *time.Location
+	Pricing     *pricingTable // nil skips cost estimates
+	ProjectSort string
+}
+
+// buildExport filters sessions, applies each session's privacy rule and
+// aggregates everything the cc-sessions shortcode renders. The result only
+// depends on its inputs, not on their order.
+func buildExport(sessions []claug.SessionStats, opts exportOptions) dataExport {
+	exports := []sessionExport{}
+	var totals claug.Usage
+	byModel := claug.ModelUsage{}
+	unpricedModels := make(map[string]bool)
+	rollups := newRollupBuilder(opts.Location)
+	projects := newProjectBuilder(opts.Location)
+	var allToolCounts []map[string]int
+
+	for _, s := range sessions {
+		if !opts.Filter.match(s) {
+			continue
+		}
+
+		rule := privacyRuleFor(s.PrivacyLevel)
+
+		model := claug.ModelName(s.Model)
+		var cost *sessionCost
+		if opts.Pricing != nil {
+			usd, ok := opts.Pricing.sessionCost(s)
+			cost = &sessionCost{usd: usd, priced: ok}
+			if !ok {
+				unpricedModels[model] = true
+			}
+		}
+		addSession(&totals, s, cost)
+		addSession(byModel.For(model), s, cost)
+		rollups.add(s, cost)
+
+		project := claug.ProjectName(s.Project)
+		if !rule.ShowProject {
+			project = privateProject
+		}
+		projects.add(project, s, cost, rule.ShowTools)
+
+		if rule.ShowTools && len(s.ToolCounts) > 0 {
+			allToolCounts = append(allToolCounts, s.ToolCounts)
+		}
+
+		if !rule.Listed {
+			continue
+		}
+
+		e := sessionExport{
+			SessionID:                   s.SessionID,
+			Model:                       model,
+			Cwd:                         "",
+			NumUserPrompts:              s.NumUserPrompts,
+			NumToolCalls:                s.NumToolCalls,
+			TotalInputTokens:            s.TotalInputTokens,
+			TotalCacheReadInputTokens:   s.TotalCacheReadInputTokens,
+			TotalCacheReadTokensDisplay: claug.FormatTokens(s.TotalCacheReadInputTokens),
+			TotalOutputTokens:           s.TotalOutputTokens,
+			TotalTokens:                 s.TotalTokens,
+			TotalTokensDisplay:          claug.FormatTokens(s.TotalTokens),
+			TotalTokensDisplayShort:     claug.FormatTokensShort(s.TotalTokens),
+			ActiveTimeSeconds:           s.ActiveTimeSeconds,
+			ActiveTimeDisplay:           claug.FormatTime(s.ActiveTimeSeconds),
+			CcVersion:                   s.ProviderVersion,
+			Env:                         s.Env,
+			Redacted:                    !rule.ShowSummary,
+		}
+		if rule.ShowSummary {
+			e.Summary = s.Summary
+		}
+		if rule.ShowProject {
+			e.Project = project
+		}
+		if cost != nil {
+			e.CostUnpriced = !cost.priced
+			if cost.priced {
+				e.EstimatedCostUSD = claug.RoundCost(cost.usd)
+				e.EstimatedCostDisplay = claug.FormatCost(cost.usd)
+			}
+		}
+
+		if s.CreatedAt != 0 {
+			t := time.Unix(s.CreatedAt, 0).In(opts.Location)
+			e.Date = t.Format(time.RFC3339)
+			e.DateDisplay = claug.FormatDate(t.Format(time.RFC3339))
+		}
+
+		exports = append(exports, e)
+	}
+
+	if len(unpricedModels) > 0 {
+		models := make([]string, 0, len(unpricedModels))
+		for m := range unpricedModels {
+			models = append(models, m)
+		}
+		sort.Strings(models)
+		log.Printf("WARNING: no price for models %v; their sessions are flagged cost_unpriced", models)
+	}
+
+	// Sort sessions by date descending (newest first)
+	sort.Slice(exports, func(i, j int) bool {
+		if exports[i].Date != exports[j].Date {
+			return exports[i].Date > exports[j].Date
+		}
+		return exports[i].SessionID < exports[j].SessionID
+	})
+
+	exportTotals := totals.Totals()
+	exportTotals.TopTools = claug.TopTools(allToolCounts, 5)
+	exportTotals.ByModel = byModel.Rows()
+	return dataExport{
+		SchemaVersion: schemaVersion,
+		Sessions:      exports,
+		Totals:        exportTotals,
+		Rollups:       rollups.export(),
+		Projects:      projects.export(opts.ProjectSort),
+	}
+
+}
+
+// exportPath returns site/data/cc_sessions.json under the blog root.
+func exportPath() string {
+	return filepath.Join(blogRoot(), "data", "cc_sessions.json")
+}
+
+// blogRoot returns CC_STATS_BLOG_ROOT, or site/ relative to the working
+// directory (scripts/build-sessions -> site/).
+func blogRoot() string {
+	if root := os.Getenv("CC_STATS_BLOG_ROOT"); root != "" {
+		return root
+	}
+	exe, err := os.Getwd()
+	if err != nil {
+		log.Fatalf("getting working directory: %v", err)
+	}
+	return filepath.Join(exe, "..", "..", "site")
+}
+
+// writeExport writes data to dataFile once it validates against the
+// published schema. It won't replace an export with a newer schema_version.
+func writeExport(data dataExport, dataFile string) error {
+	if err := checkSchemaVersion(dataFile); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dataFile), 0o755); err != nil {
+		return fmt.Errorf("creating data directory: %w", err)
+	}
+	if err := writeJSONAtomic(dataFile, data, "  ", validateExport); err != nil {
+		return fmt.Errorf("writing %s: %w", dataFile, err)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/howiewang/personal-blog/scripts/claug"
+	"github.com/howiewang/personal-blog/scripts/claug/claugtest"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+func loadTestSessions(t *testing.T) []claug.SessionStats {
+	t.Helper()
+	data, err := os.ReadFile("testdata/sessions.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sessions []claug.SessionStats
+	if err := json.Unmarshal(data, &sessions); err != nil {
+		t.Fatal(err)
+	}
+	return sessions
+}
+
+func testExportOptions(t *testing.T) exportOptions {
+	t.Helper()
+	pricing, err := loadPricing("testdata/pricing.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter, err := (&filterFlags{from: defaultFromDate, minTokens: 1}).parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return exportOptions{
+		Filter:      filter,
+		Location:    time.UTC,
+		Pricing:     pricing,
+		ProjectSort: "tokens",
+	}
+}
+
+// checkGolden compares got with testdata/name, rewriting it under -update.
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from golden file; run go test -update and review the diff\n--- got ---\n%s", name, got)
+	}
+}
+
+// TestSyncGolden runs the fetch + export pipeline against a fake claug API
+// and compares the exact cc_sessions.json bytes.
+func TestSyncGolden(t *testing.T) {
+	srv := claugtest.NewServer(loadTestSessions(t)...)
+	defer srv.Close()
+	// A flaky first page must not change the output.
+	srv.FailNext(claugtest.Failure{Status: 503})
+
+	opts := testExportOptions(t)
+	sessions, err := fetchSessions(srv.Client(claug.DefaultRetryPolicy), opts.Filter, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(t.TempDir(), "data", "cc_sessions.json")
+	if err := writeExport(buildExport(sessions, opts), out); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "cc_sessions.golden.json", got)
+}
+
+func TestBuildExportIgnoresInputOrder(t *testing.T) {
+	sessions := loadTestSessions(t)
+	opts := testExportOptions(t)
+	want, _ := json.Marshal(buildExport(sessions, opts))
+
+	reversed := make([]claug.SessionStats, len(sessions))
+	for i, s := range sessions {
+		reversed[len(sessions)-1-i] = s
+	}
+	got, _ := json.Marshal(buildExport(reversed, opts))
+	if !bytes.Equal(got, want) {
+		t.Error("export depends on session order")
+	}
+}
+
+func TestBuildExportPrivacy(t *testing.T) {
+	data := buildExport(loadTestSessions(t), testExportOptions(t))
+
+	listed := make(map[string]sessionExport)
+	for _, e := range data.Sessions {
+		listed[e.SessionID] = e
+	}
+	if _, ok := listed["sess-03"]; ok {
+		t.Error("hidden session sess-03 was listed")
+	}
+	for _, id := range []string{"sess-02", "sess-08"} {
+		e, ok := listed[id]
+		if !ok {
+			t.Fatalf("%s missing from sessions", id)
+		}
+		if !e.Redacted || e.Summary != "" || e.Project != "" {
+			t.Errorf("%s not redacted: %+v", id, e)
+		}
+	}
+	if e := listed["sess-06"]; e.Redacted || e.Summary == "" {
+		t.Errorf("empty privacy level should export in full: %+v", e)
+	}
+
+	// Hidden and redacted sessions still count towards the totals.
+	if data.Totals.SessionCount != 7 {
+		t.Errorf("SessionCount = %d, want 7", data.Totals.SessionCount)
+	}
+	for _, p := range data.Projects {
+		if p.Project == "secret-client" || p.Project == "hidden-thing" {
+			t.Errorf("private project %q leaked into projects", p.Project)
+		}
+	}
+}
+
+func TestPrivacyRuleFor(t *testing.T) {
+	tests := []struct {
+		level string
+		want  privacyRule
+	}{
+		{"", privacyPolicy[privacyFull]},
+		{"full", privacyPolicy[privacyFull]},
+		{"metrics_only", privacyPolicy[privacyMetricsOnly]},
+		{"hidden", privacyRule{}},
+		{"something_new", privacyPolicy[privacyMetricsOnly]},
+	}
+	for _, tt := range tests {
+		if got := privacyRuleFor(tt.level); got != tt.want {
+			t.Errorf("privacyRuleFor(%q) = %+v, want %+v", tt.level, got, tt.want)
+		}
+	}
+}
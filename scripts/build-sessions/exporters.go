@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/howiewang/personal-blog/scripts/claug"
+)
+
+// hugoFormat is the cc_sessions.json file the site is built from.
+const hugoFormat = "hugo"
+
+// exporter writes the sessions of an export in one file format.
+type exporter struct {
+	file  string // file name under --export-dir
+	write func(w io.Writer, sessions []sessionExport) error
+}
+
+// exporters are the tabular --format values. Each writes the same rows and
+// columns as the site lists, after privacy rules, for spreadsheets and
+// notebooks; hugoFormat is handled separately by writeExport.
+var exporters = map[string]exporter{
+	"csv":      {"cc_sessions.csv", writeSessionsCSV},
+	"jsonl":    {"cc_sessions.jsonl", writeSessionsJSONL},
+	"columnar": {"cc_sessions.columns.json", writeSessionsColumnar},
+}
+
+// sessionColumn is one column of the tabular exports. Display strings are
+// left out; they can be derived from the raw values.
+type sessionColumn struct {
+	name string
+	typ  string // string, int, float or bool
+	get  func(s *sessionExport) any
+}
+
+var sessionColumns = []sessionColumn{
+	{"session_id", "string", func(s *sessionExport) any { return s.SessionID }},
+	{"date", "string", func(s *sessionExport) any { return s.Date }},
+	{"env", "string", func(s *sessionExport) any { return s.Env }},
+	{"project", "string", func(s *sessionExport) any { return s.Project }},
+	{"model", "string", func(s *sessionExport) any { return s.Model }},
+	{"cc_version", "string", func(s *sessionExport) any { return s.CcVersion }},
+	{"summary", "string", func(s *sessionExport) any { return s.Summary }},
+	{"redacted", "bool", func(s *sessionExport) any { return s.Redacted }},
+	{"num_user_prompts", "int", func(s *sessionExport) any { return int64(s.NumUserPrompts) }},
+	{"num_tool_calls", "int", func(s *sessionExport) any { return int64(s.NumToolCalls) }},
+	{"total_input_tokens", "int", func(s *sessionExport) any { return s.TotalInputTokens }},
+	{"total_cache_read_input_tokens", "int", func(s *sessionExport) any { return s.TotalCacheReadInputTokens }},
+	{"total_output_tokens", "int", func(s *sessionExport) any { return s.TotalOutputTokens }},
+	{"total_tokens", "int", func(s *sessionExport) any { return s.TotalTokens }},
+	{"active_time_seconds", "int", func(s *sessionExport) any { return int64(s.ActiveTimeSeconds) }},
+	{"estimated_cost_usd", "float", func(s *sessionExport) any { return s.EstimatedCostUSD }},
+	{"cost_unpriced", "bool", func(s *sessionExport) any { return s.CostUnpriced }},
+}
+
+// writeSessionsCSV writes a header row and one row per session.
+func writeSessionsCSV(w io.Writer, sessions []sessionExport) error {
+	cw := csv.NewWriter(w)
+	row := make([]string, len(sessionColumns))
+	for i, c := range sessionColumns {
+		row[i] = c.name
+	}
+	_ = cw.Write(row)
+	for i := range sessions {
+		for j, c := range sessionColumns {
+			row[j] = formatCell(c.get(&sessions[i]))
+		}
+		_ = cw.Write(row)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatCell(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	panic(fmt.Sprintf("unsupported column value %T", v))
+}
+
+// writeSessionsJSONL writes one JSON object per session, keys in column order.
+func writeSessionsJSONL(w io.Writer, sessions []sessionExport) error {
+	var buf bytes.Buffer
+	for i := range sessions {
+		buf.Reset()
+		buf.WriteByte('{')
+		for j, c := range sessionColumns {
+			if j > 0 {
+				buf.WriteByte(',')
+			}
+			key, _ := json.Marshal(c.name)
+			val, err := json.Marshal(c.get(&sessions[i]))
+			if err != nil {
+				return err
+			}
+			buf.Write(key)
+			buf.WriteByte(':')
+			buf.Write(val)
+		}
+		buf.WriteString("}\n")
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// columnarExport stores each column as one typed array, so a notebook can
+// load it straight into arrays or a data frame without parsing rows:
+//
+//	cols = {c["name"]: np.array(c["values"]) for c in data["columns"]}
+type columnarExport struct {
+	SchemaVersion int              `json:"schema_version"`
+	RowCount      int              `json:"row_count"`
+	Columns       []columnarColumn `json:"columns"`
+}
+
+type columnarColumn struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Values []any  `json:"values"`
+}
+
+func writeSessionsColumnar(w io.Writer, sessions []sessionExport) error {
+	out := columnarExport{
+		SchemaVersion: schemaVersion,
+		RowCount:      len(sessions),
+		Columns:       make([]columnarColumn, len(sessionColumns)),
+	}
+	for j, c := range sessionColumns {
+		values := make([]any, len(sessions))
+		for i := range sessions {
+			values[i] = c.get(&sessions[i])
+		}
+		out.Columns[j] = columnarColumn{Name: c.name, Type: c.typ, Values: values}
+	}
+	return json.NewEncoder(w).Encode(out)
+}
+
+// outputFlags are shared by every command that writes exports.
+type outputFlags struct {
+	out       string
+	formats   claug.StringList
+	exportDir string
+	chartsDir string
+	svgDir    string
+}
+
+func registerOutputFlags(fs *flag.FlagSet) *outputFlags {
+	o := &outputFlags{}
+	fs.StringVar(&o.out, "out", envOr("CC_STATS_OUT", exportPath()), "site export to write for --format hugo; data/cc_sessions.json under CC_STATS_BLOG_ROOT or site/ by default [CC_STATS_OUT]")
+	_ = o.formats.Set(envOr("CC_STATS_FORMAT", hugoFormat))
+	fs.Func("format", "export formats to write: "+strings.Join(formatNames(), ", ")+" (default hugo); repeatable, replacing the default [CC_STATS_FORMAT]", o.setFormat())
+	fs.StringVar(&o.exportDir, "export-dir", envOr("CC_STATS_EXPORT_DIR", "exports"), "directory for the csv, jsonl and columnar exports [CC_STATS_EXPORT_DIR]")
+	fs.StringVar(&o.chartsDir, "charts-dir", chartsPath(), "directory for the charts datasets; scripts/plots/data by default [CC_STATS_CHARTS_DIR]")
+	fs.StringVar(&o.svgDir, "svg-dir", svgPath(), "directory for the svg charts; static/img/charts under the blog root by default [CC_STATS_SVG_DIR]")
+	return o
+}
+
+// setFormat returns a flag.Func setter whose first call replaces the
+// default formats and later calls add to them.
+func (o *outputFlags) setFormat() func(string) error {
+	replaced := false
+	return func(v string) error {
+		if !replaced {
+			o.formats, replaced = nil, true
+		}
+		return o.formats.Set(v)
+	}
+}
+
+// validate checks every --format is known.
+func (o *outputFlags) validate() error {
+	if len(o.formats) == 0 {
+		return fmt.Errorf("--format: no formats given")
+	}
+	for _, f := range o.formats {
+		if _, ok := exporters[f]; !ok && f != hugoFormat && f != chartsFormat && f != svgFormat {
+			return fmt.Errorf("--format: unknown format %q (want %s)", f, strings.Join(formatNames(), ", "))
+		}
+	}
+	return nil
+}
+
+// write builds the export from sessions and writes it in every selected
+// format, each file atomically.
+func (o *outputFlags) write(sessions []claug.SessionStats, opts exportOptions) error {
+	data := buildExport(sessions, opts)
+	for _, f := range o.formats {
+		switch f {
+		case hugoFormat:
+			if err := writeExport(data, o.out); err != nil {
+				return err
+			}
+			log.Printf("exported %d sessions to %s", len(data.Sessions), o.out)
+			continue
+		case chartsFormat:
+			if err := writeCharts(o.chartsDir, buildCharts(sessions, opts, data)); err != nil {
+				return err
+			}
+			log.Printf("wrote chart datasets to %s", o.chartsDir)
+			continue
+		case svgFormat:
+			if err := writeSVGCharts(o.svgDir, buildSVGCharts(data)); err != nil {
+				return err
+			}
+			log.Printf("wrote svg charts to %s", o.svgDir)
+			continue
+		}
+		path := filepath.Join(o.exportDir, exporters[f].file)
+		if err := writeTabular(path, exporters[f], data.Sessions); err != nil {
+			return err
+		}
+		log.Printf("exported %d sessions as %s to %s", len(data.Sessions), f, path)
+	}
+	return nil
+}
+
+func writeTabular(path string, e exporter, sessions []sessionExport) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("creating export directory: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := e.write(&buf, sessions); err != nil {
+		return fmt.Errorf("encoding %s: %w", path, err)
+	}
+	if err := writeFileAtomic(path, buf.Bytes()); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return nil
+}
+
+func formatNames() []string {
+	names := []string{hugoFormat, chartsFormat, svgFormat}
+	for name := range exporters {
+		names = append(names, name)
+	}
+	sort.Strings(names[3:])
+	return names
+}
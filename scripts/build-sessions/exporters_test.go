@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/howiewang/personal-blog/scripts/claug"
+)
+
+func testSessionExports(t *testing.T) []sessionExport {
+	t.Helper()
+	return buildExport(loadTestSessions(t), testExportOptions(t)).Sessions
+}
+
+// TestSessionColumnsCoverExport fails when a field is added to sessionExport
+// without deciding whether the tabular exports carry it.
+func TestSessionColumnsCoverExport(t *testing.T) {
+	columns := make(map[string]bool)
+	for _, c := range sessionColumns {
+		columns[c.name] = true
+	}
+	skipped := map[string]bool{"cwd": true} // always empty
+	typ := reflect.TypeOf(sessionExport{})
+	for i := 0; i < typ.NumField(); i++ {
+		name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+		if strings.HasSuffix(name, "_display") || strings.HasSuffix(name, "_display_short") || skipped[name] {
+			continue
+		}
+		if !columns[name] {
+			t.Errorf("sessionExport field %s has no column", name)
+		}
+	}
+}
+
+func TestWriteSessionsCSV(t *testing.T) {
+	sessions := testSessionExports(t)
+	var buf bytes.Buffer
+	if err := writeSessionsCSV(&buf, sessions); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != len(sessions)+1 {
+		t.Fatalf("got %d rows, want header + %d", len(rows), len(sessions))
+	}
+	if rows[0][0] != "session_id" || rows[1][0] != sessions[0].SessionID {
+		t.Errorf("first column = %q, %q; want session_id, %q", rows[0][0], rows[1][0], sessions[0].SessionID)
+	}
+}
+
+func TestWriteSessionsJSONL(t *testing.T) {
+	sessions := testSessionExports(t)
+	var buf bytes.Buffer
+	if err := writeSessionsJSONL(&buf, sessions); err != nil {
+		t.Fatal(err)
+	}
+	sc := bufio.NewScanner(&buf)
+	n := 0
+	for sc.Scan() {
+		var row map[string]any
+		if err := json.Unmarshal(sc.Bytes(), &row); err != nil {
+			t.Fatalf("line %d: %v", n+1, err)
+		}
+		if len(row) != len(sessionColumns) || row["session_id"] != sessions[n].SessionID ||
+			row["total_tokens"] != float64(sessions[n].TotalTokens) {
+			t.Errorf("line %d = %v, want session %s", n+1, row, sessions[n].SessionID)
+		}
+		if !strings.HasPrefix(sc.Text(), `{"session_id":`) {
+			t.Errorf("line %d keys out of column order: %s", n+1, sc.Text())
+		}
+		n++
+	}
+	if n != len(sessions) {
+		t.Errorf("got %d lines, want %d", n, len(sessions))
+	}
+}
+
+func TestWriteSessionsColumnar(t *testing.T) {
+	sessions := testSessionExports(t)
+	var buf bytes.Buffer
+	if err := writeSessionsColumnar(&buf, sessions); err != nil {
+		t.Fatal(err)
+	}
+	var got columnarExport
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.SchemaVersion != schemaVersion || got.RowCount != len(sessions) || len(got.Columns) != len(sessionColumns) {
+		t.Fatalf("header = %d/%d/%d columns", got.SchemaVersion, got.RowCount, len(got.Columns))
+	}
+	for i, c := range got.Columns {
+		if c.Name != sessionColumns[i].name || c.Type != sessionColumns[i].typ || len(c.Values) != len(sessions) {
+			t.Errorf("column %d = %s %s with %d values", i, c.Name, c.Type, len(c.Values))
+		}
+	}
+	if got.Columns[0].Values[1] != sessions[1].SessionID {
+		t.Errorf("session_id[1] = %v, want %s", got.Columns[0].Values[1], sessions[1].SessionID)
+	}
+}
+
+func TestOutputFlags(t *testing.T) {
+	t.Setenv("CC_STATS_FORMAT", "")
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	o := registerOutputFlags(fs)
+	if !reflect.DeepEqual([]string(o.formats), []string{hugoFormat}) {
+		t.Errorf("default formats = %v, want [hugo]", o.formats)
+	}
+
+	dir := t.TempDir()
+	if err := fs.Parse([]string{"--format", "csv", "--format", "jsonl,columnar", "--export-dir", dir, "--out", filepath.Join(dir, "site.json")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.write(loadTestSessions(t), testExportOptions(t)); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"cc_sessions.csv", "cc_sessions.jsonl", "cc_sessions.columns.json"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Error(err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "site.json")); err == nil {
+		t.Error("--format without hugo still wrote the site export")
+	}
+
+	o.formats = claug.StringList{"xlsx"}
+	if err := o.validate(); err == nil {
+		t.Error("validate accepted an unknown format")
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/howiewang/personal-blog/scripts/claug"
+)
+
+// sessionFilter selects which sessions end up in the export. The date window,
+// provider, model and full-path project parts are also sent to the API so
+// less data crosses the wire; everything is re-checked locally in match.
+type sessionFilter struct {
+	From            time.Time
+	To              time.Time
+	Projects        []string // include globs; empty means all
+	ExcludeProjects []string // exclude globs, applied after includes
+	Models          []string
+	Providers       []string
+	Envs            []string // claug envs to fetch from and export; empty means all active
+	MinTokens       int64
+}
+
+// filterFlags holds the raw flag values until they are parsed into a
+// sessionFilter. Each flag defaults to its CC_STATS_* environment variable.
+type filterFlags struct {
+	from, to                  string
+	projects, excludeProjects claug.StringList
+	models, providers         claug.StringList
+	envs                      claug.StringList
+	minTokens                 int64
+}
+
+func registerFilterFlags(fs *flag.FlagSet) *filterFlags {
+	f := &filterFlags{}
+	fs.StringVar(&f.from, "from", envOr("CC_STATS_FROM", defaultFromDate), "only sessions created on or after this date (YYYY-MM-DD or RFC3339) [CC_STATS_FROM]")
+	fs.StringVar(&f.to, "to", os.Getenv("CC_STATS_TO"), "only sessions created before this date (YYYY-MM-DD or RFC3339) [CC_STATS_TO]")
+	_ = f.projects.Set(os.Getenv("CC_STATS_PROJECT"))
+	fs.Var(&f.projects, "project", "include projects matching this glob; repeatable [CC_STATS_PROJECT]")
+	_ = f.excludeProjects.Set(os.Getenv("CC_STATS_EXCLUDE_PROJECT"))
+	fs.Var(&f.excludeProjects, "exclude-project", "exclude projects matching this glob; repeatable [CC_STATS_EXCLUDE_PROJECT]")
+	_ = f.models.Set(os.Getenv("CC_STATS_MODEL"))
+	fs.Var(&f.models, "model", "only sessions using this model (glob); repeatable [CC_STATS_MODEL]")
+	_ = f.providers.Set(os.Getenv("CC_STATS_PROVIDER"))
+	fs.Var(&f.providers, "provider", "only sessions from this provider; repeatable [CC_STATS_PROVIDER]")
+	_ = f.envs.Set(os.Getenv("CC_STATS_ENV"))
+	fs.Var(&f.envs, "env", "only fetch from and export these claug envs (glob) out of the active ones; repeatable [CC_STATS_ENV]")
+	fs.Int64Var(&f.minTokens, "min-tokens", envInt64("CC_STATS_MIN_TOKENS", 1), "skip sessions with fewer total tokens [CC_STATS_MIN_TOKENS]")
+	return f
+}
+
+func (f *filterFlags) parse() (sessionFilter, error) {
+	from, err := claug.ParseDate(f.from)
+	if err != nil {
+		return sessionFilter{}, fmt.Errorf("--from: %w", err)
+	}
+	to, err := claug.ParseDate(f.to)
+	if err != nil {
+		return sessionFilter{}, fmt.Errorf("--to: %w", err)
+	}
+	if !from.IsZero() && !to.IsZero() && !to.After(from) {
+		return sessionFilter{}, fmt.Errorf("--to (%s) must be after --from (%s)", f.to, f.from)
+	}
+	for _, pattern := range append(append([]string{}, f.projects...), f.excludeProjects...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return sessionFilter{}, fmt.Errorf("bad project glob %q: %w", pattern, err)
+		}
+	}
+	for _, pattern := range f.models {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return sessionFilter{}, fmt.Errorf("bad model glob %q: %w", pattern, err)
+		}
+	}
+	for _, pattern := range f.envs {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return sessionFilter{}, fmt.Errorf("bad env glob %q: %w", pattern, err)
+		}
+	}
+
+	return sessionFilter{
+		From:            from,
+		To:              to,
+		Projects:        f.projects,
+		ExcludeProjects: f.excludeProjects,
+		Models:          f.models,
+		Providers:       f.providers,
+		Envs:            f.envs,
+		MinTokens:       f.minTokens,
+	}, nil
+}
+
+// apply adds the server-side subset of the filter to an /api/sessions query.
+// Multi-valued or glob filters are left to match.
+func (f sessionFilter) apply(q url.Values) {
+	if !f.From.IsZero() {
+		q.Set("from", f.From.UTC().Format(time.RFC3339))
+	}
+	if !f.To.IsZero() {
+		q.Set("to", f.To.UTC().Format(time.RFC3339))
+	}
+	// The server compares raw project values, so a name that match could
+	// accept as a display name must stay local. Display names never contain
+	// a slash.
+	if len(f.Projects) == 1 && !hasGlobMeta(f.Projects[0]) && strings.Contains(f.Projects[0], "/") {
+		q.Set("project", f.Projects[0])
+	}
+	if len(f.Models) == 1 && !hasGlobMeta(f.Models[0]) {
+		q.Set("model", f.Models[0])
+	}
+	if len(f.Providers) == 1 {
+		q.Set("provider", f.Providers[0])
+	}
+}
+
+// query returns the server-side part of the filter as an encoded string, so
+// the cache can tell when it was filled with a different query.
+func (f sessionFilter) query() string {
+	q := url.Values{}
+	f.apply(q)
+	return q.Encode()
+}
+
+// match reports whether a session passes every filter.
+func (f sessionFilter) match(s claug.SessionStats) bool {
+	if s.TotalTokens < f.MinTokens {
+		return false
+	}
+	if !claug.InWindow(f.From, f.To, s.CreatedAt) {
+		return false
+	}
+	// Project globs may target either the raw value or the display name.
+	if len(f.Projects) > 0 && !claug.MatchProject(f.Projects, s.Project) {
+		return false
+	}
+	if claug.MatchProject(f.ExcludeProjects, s.Project) {
+		return false
+	}
+	if len(f.Models) > 0 && !claug.MatchAny(f.Models, s.Model) {
+		return false
+	}
+	if len(f.Providers) > 0 && !claug.MatchAny(f.Providers, s.Provider) {
+		return false
+	}
+	if len(f.Envs) > 0 && !claug.MatchAny(f.Envs, s.Env) {
+		return false
+	}
+	return true
+}
+
+func hasGlobMeta(s string) bool {
+	return strings.ContainsAny(s, `*?[\`)
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func envInt64(key string, fallback int64) int64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		log.Fatalf("parsing %s=%q: %v", key, v, err)
+	}
+	return n
+}
+
+func envDuration(key string, fallback time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Fatalf("parsing %s=%q: %v", key, v, err)
+	}
+	return d
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/howiewang/personal-blog/scripts/claug"
+	"github.com/howiewang/personal-blog/scripts/claug/claugtest"
+)
+
+func TestFilterFlagsParse(t *testing.T) {
+	f, err := (&filterFlags{from: "2026-01-01", to: "2026-04-01T00:00:00-05:00", minTokens: 1}).parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC); !f.From.Equal(want) {
+		t.Errorf("From = %v, want %v", f.From, want)
+	}
+	if want := time.Date(2026, 4, 1, 5, 0, 0, 0, time.UTC); !f.To.Equal(want) {
+		t.Errorf("To = %v, want %v", f.To, want)
+	}
+
+	for _, bad := range []filterFlags{
+		{from: "yesterday"},
+		{from: "2026-03-01", to: "2026-02-01"},
+		{projects: claug.StringList{"[unterminated"}},
+	} {
+		if _, err := bad.parse(); err == nil {
+			t.Errorf("parse(%+v) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	created := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC).Unix()
+	s := claug.SessionStats{
+		Project:     "/Users/howie/code/personal-blog",
+		Model:       "claude-opus-4-6",
+		Provider:    "claude-code",
+		Env:         "prod",
+		CreatedAt:   created,
+		TotalTokens: 5000,
+	}
+	tests := []struct {
+		name   string
+		filter sessionFilter
+		want   bool
+	}{
+		{"empty filter", sessionFilter{}, true},
+		{"min tokens", sessionFilter{MinTokens: 5001}, false},
+		{"before from", sessionFilter{From: time.Unix(created+1, 0)}, false},
+		{"to is exclusive", sessionFilter{To: time.Unix(created, 0)}, false},
+		{"project by display name", sessionFilter{Projects: []string{"personal-*"}}, true},
+		{"project by raw path", sessionFilter{Projects: []string{"/Users/*/code/*"}}, true},
+		{"project excluded", sessionFilter{ExcludeProjects: []string{"personal-blog"}}, false},
+		{"other project", sessionFilter{Projects: []string{"claug"}}, false},
+		{"model glob", sessionFilter{Models: []string{"claude-opus-*"}}, true},
+		{"model mismatch", sessionFilter{Models: []string{"claude-sonnet-*"}}, false},
+		{"provider", sessionFilter{Providers: []string{"codex"}}, false},
+		{"env", sessionFilter{Envs: []string{"prod"}}, true},
+		{"other env", sessionFilter{Envs: []string{"staging"}}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.match(s); got != tt.want {
+			t.Errorf("%s: match = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	undated := s
+	undated.CreatedAt = 0
+	if (sessionFilter{To: time.Unix(created, 0)}).match(undated) {
+		t.Error("undated session matched a date window")
+	}
+	if !(sessionFilter{}).match(undated) {
+		t.Error("undated session dropped without a date window")
+	}
+}
+
+func TestFilterApply(t *testing.T) {
+	f := sessionFilter{
+		From:      time.Date(2026, 2, 7, 0, 0, 0, 0, time.UTC),
+		Projects:  []string{"claug"},
+		Models:    []string{"claude-*"},
+		Providers: []string{"claude-code", "codex"},
+	}
+	q := url.Values{}
+	f.apply(q)
+	want := url.Values{"from": {"2026-02-07T00:00:00Z"}}
+	if q.Encode() != want.Encode() {
+		t.Errorf("apply = %q, want %q", q.Encode(), want.Encode())
+	}
+
+	f.Projects = []string{"/Users/x/claug"}
+	q = url.Values{}
+	f.apply(q)
+	want.Set("project", "/Users/x/claug")
+	if q.Encode() != want.Encode() {
+		t.Errorf("apply = %q, want %q", q.Encode(), want.Encode())
+	}
+}
+
+// TestFetchSessionsProjectDisplayName checks --project can name a project by
+// its display name even though the server only knows the raw path.
+func TestFetchSessionsProjectDisplayName(t *testing.T) {
+	created := time.Date(2026, 3, 4, 12, 0, 0, 0, time.UTC).Unix()
+	srv := claugtest.NewServer(
+		claug.SessionStats{SessionID: "a", CreatedAt: created, TotalTokens: 10, Project: "/Users/x/personal-blog"},
+		claug.SessionStats{SessionID: "b", CreatedAt: created, TotalTokens: 10, Project: "/Users/x/claug"},
+	)
+	defer srv.Close()
+
+	for _, project := range []string{"personal-blog", "/Users/x/personal-blog"} {
+		filter, err := (&filterFlags{projects: claug.StringList{project}}).parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sessions, err := fetchSessions(srv.Client(claug.DefaultRetryPolicy), filter, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, s := range sessions {
+			if filter.match(s) {
+				got = append(got, s.SessionID)
+			}
+		}
+		if len(got) != 1 || got[0] != "a" {
+			t.Errorf("--project %s selected %v, want [a]", project, got)
+		}
+	}
+}
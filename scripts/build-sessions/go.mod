@@ -2,4 +2,13 @@ module github.com/howiewang/personal-blog/scripts/build-sessions
 
 go 1.23.4
 
-require gopkg.in/yaml.v3 v3.0.1
+require github.com/howiewang/personal-blog/scripts/claug v0.0.0
+
+require (
+	github.com/santhosh-tekuri/jsonschema/v6 v6.0.3
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require golang.org/x/text v0.14.0 // indirect
+
+replace github.com/howiewang/personal-blog/scripts/claug => ../claug
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/howiewang/personal-blog/scripts/claug"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultPricingFile is looked up relative to the working directory when
+// neither --pricing nor CC_STATS_PRICING is set.
+const defaultPricingFile = "pricing.yaml"
+
+// pricingTable is the parsed pricing file. Prices are USD per million tokens.
+// Models are matched in order against their glob, so list specific patterns
+// before broader ones.
+type pricingTable struct {
+	Models []modelPricing `json:"models" yaml:"models"`
+}
+
+type modelPricing struct {
+	Match  string       `json:"match" yaml:"match"`
+	Prices []priceEntry `json:"prices" yaml:"prices"`
+}
+
+// priceEntry is one set of prices and the date (YYYY-MM-DD, UTC) from which
+// it applies.
+type priceEntry struct {
+	Effective string  `json:"effective" yaml:"effective"`
+	Input     float64 `json:"input" yaml:"input"`
+	CacheRead float64 `json:"cache_read" yaml:"cache_read"`
+	Output    float64 `json:"output" yaml:"output"`
+
+	effective time.Time
+}
+
+// loadPricing reads a YAML or JSON pricing table. With an empty path it tries
+// defaultPricingFile and returns nil, nil when that does not exist, so cost
+// estimation is simply skipped.
+func loadPricing(file string) (*pricingTable, error) {
+	explicit := file != ""
+	if !explicit {
+		file = defaultPricingFile
+	}
+	data, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) && !explicit {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading pricing file: %w", err)
+	}
+
+	var table pricingTable
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".json":
+		err = json.Unmarshal(data, &table)
+	default:
+		err = yaml.Unmarshal(data, &table)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", file, err)
+	}
+
+	for i := range table.Models {
+		m := &table.Models[i]
+		if _, err := path.Match(m.Match, ""); err != nil {
+			return nil, fmt.Errorf("%s: bad model glob %q: %w", file, m.Match, err)
+		}
+		if len(m.Prices) == 0 {
+			return nil, fmt.Errorf("%s: model %q has no prices", file, m.Match)
+		}
+		for j := range m.Prices {
+			p := &m.Prices[j]
+			t, err := time.Parse("2006-01-02", p.Effective)
+			if err != nil {
+				return nil, fmt.Errorf("%s: model %q: bad effective date %q: %w", file, m.Match, p.Effective, err)
+			}
+			p.effective = t
+		}
+		// Newest first, so priceAt can stop at the first entry in effect.
+		sort.Slice(m.Prices, func(a, b int) bool {
+			return m.Prices[a].effective.After(m.Prices[b].effective)
+		})
+	}
+	return &table, nil
+}
+
+// priceAt returns the price for model in effect at t. Sessions that predate
+// every entry use the oldest one, since a model's launch price is the best
+// estimate available.
+func (pt *pricingTable) priceAt(model string, t time.Time) (priceEntry, bool) {
+	for _, m := range pt.Models {
+		if ok, _ := path.Match(m.Match, model); !ok {
+			continue
+		}
+		for _, p := range m.Prices {
+			if t.IsZero() || !p.effective.After(t) {
+				return p, true
+			}
+		}
+		return m.Prices[len(m.Prices)-1], true
+	}
+	return priceEntry{}, false
+}
+
+// sessionCost estimates what a session cost in USD. ok is false when the
+// session's model has no price.
+func (pt *pricingTable) sessionCost(s claug.SessionStats) (cost float64, ok bool) {
+	var created time.Time
+	if s.CreatedAt != 0 {
+		created = time.Unix(s.CreatedAt, 0)
+	}
+	p, ok := pt.priceAt(s.Model, created)
+	if !ok {
+		return 0, false
+	}
+	cost = (float64(s.TotalInputTokens)*p.Input +
+		float64(s.TotalCacheReadInputTokens)*p.CacheRead +
+		float64(s.TotalOutputTokens)*p.Output) / 1_000_000
+	return cost, true
+}
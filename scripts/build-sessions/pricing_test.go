@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/howiewang/personal-blog/scripts/claug"
+)
+
+func TestPriceAt(t *testing.T) {
+	pt, err := loadPricing("testdata/pricing.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		model     string
+		at        time.Time
+		wantInput float64
+		wantOK    bool
+	}{
+		{"claude-opus-4-6", time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), 5, true},
+		{"claude-opus-4-6", time.Date(2026, 2, 10, 0, 0, 0, 0, time.UTC), 5, true},
+		{"claude-opus-4-6", time.Date(2026, 2, 9, 23, 0, 0, 0, time.UTC), 10, true},
+		{"claude-opus-4-6", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), 10, true}, // before any entry
+		{"claude-opus-4-6", time.Time{}, 5, true},
+		{"claude-sonnet-4-5-20250929", time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), 3, true},
+		{"gpt-5", time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), 0, false},
+	}
+	for _, tt := range tests {
+		p, ok := pt.priceAt(tt.model, tt.at)
+		if ok != tt.wantOK || p.Input != tt.wantInput {
+			t.Errorf("priceAt(%q, %v) = %v/%v, want input %v/%v", tt.model, tt.at, p.Input, ok, tt.wantInput, tt.wantOK)
+		}
+	}
+}
+
+func TestSessionCost(t *testing.T) {
+	pt, err := loadPricing("testdata/pricing.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := claug.SessionStats{
+		Model:                     "claude-sonnet-4-5",
+		CreatedAt:                 time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC).Unix(),
+		TotalInputTokens:          1_000_000,
+		TotalCacheReadInputTokens: 2_000_000,
+		TotalOutputTokens:         100_000,
+	}
+	cost, ok := pt.sessionCost(s)
+	// 3 + 2*0.3 + 0.1*15
+	if want := 5.1; !ok || claug.RoundCost(cost) != want {
+		t.Errorf("sessionCost = %v/%v, want %v", cost, ok, want)
+	}
+}
+
+func TestLoadPricingMissingFile(t *testing.T) {
+	if _, err := loadPricing("testdata/missing.yaml"); err == nil {
+		t.Error("an explicit missing pricing file should be an error")
+	}
+}
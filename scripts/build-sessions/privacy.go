@@ -0,0 +1,48 @@
+package main
+
+// privacyRule describes what a session at a given privacy level may reveal in
+// the exported data. Metrics (tokens, time, tool call counts) always feed the
+// totals; the rule only controls what identifies the work.
+type privacyRule struct {
+	// Listed sessions appear in the exported session list.
+	Listed bool
+	// ShowSummary exports the session summary.
+	ShowSummary bool
+	// ShowProject exports the project name.
+	ShowProject bool
+	// ShowTools lets the session's tool names contribute to top_tools.
+	ShowTools bool
+}
+
+const (
+	privacyFull        = "full"
+	privacyMetricsOnly = "metrics_only"
+	privacyHidden      = "hidden"
+)
+
+// privacyPolicy maps claug privacy levels to export rules.
+var privacyPolicy = map[string]privacyRule{
+	privacyFull: {
+		Listed:      true,
+		ShowSummary: true,
+		ShowProject: true,
+		ShowTools:   true,
+	},
+	privacyMetricsOnly: {
+		Listed: true,
+	},
+	privacyHidden: {},
+}
+
+// privacyRuleFor returns the rule for a session's privacy level. An empty
+// level is treated as full (the claug default); unknown levels fall back to
+// metrics_only so a new server-side level never leaks summaries.
+func privacyRuleFor(level string) privacyRule {
+	if level == "" {
+		level = privacyFull
+	}
+	if rule, ok := privacyPolicy[level]; ok {
+		return rule
+	}
+	return privacyPolicy[privacyMetricsOnly]
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/howiewang/personal-blog/scripts/claug"
+)
+
+const (
+	// privateProject groups sessions whose privacy rule hides the project.
+	privateProject = "(private)"
+)
+
+// projectSortKeys are the accepted --project-sort values, each ordering
+// projects descending.
+var projectSortKeys = map[string]func(a, b *projectAgg) bool{
+	"tokens":       func(a, b *projectAgg) bool { return a.Total > b.Total },
+	"sessions":     func(a, b *projectAgg) bool { return a.Sessions > b.Sessions },
+	"active_time":  func(a, b *projectAgg) bool { return a.ActiveTime > b.ActiveTime },
+	"tool_calls":   func(a, b *projectAgg) bool { return a.ToolCalls > b.ToolCalls },
+	"cost":         func(a, b *projectAgg) bool { return a.Cost > b.Cost },
+	"last_session": func(a, b *projectAgg) bool { return a.last > b.last },
+}
+
+func validProjectSort(key string) error {
+	if _, ok := projectSortKeys[key]; ok {
+		return nil
+	}
+	keys := make([]string, 0, len(projectSortKeys))
+	for k := range projectSortKeys {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return fmt.Errorf("unknown project sort %q (want one of %s)", key, strings.Join(keys, ", "))
+}
+
+type projectExport struct {
+	Project                     string            `json:"project"`
+	SessionCount                int               `json:"session_count"`
+	TotalTokens                 int64             `json:"total_tokens"`
+	TotalTokensDisplay          string            `json:"total_tokens_display"`
+	TotalInputTokens            int64             `json:"total_input_tokens"`
+	TotalCacheReadInputTokens   int64             `json:"total_cache_read_input_tokens"`
+	TotalCacheReadTokensDisplay string            `json:"total_cache_read_tokens_display"`
+	TotalOutputTokens           int64             `json:"total_output_tokens"`
+	TotalToolCalls              int               `json:"total_tool_calls"`
+	TotalActiveTimeSeconds      int               `json:"total_active_time_seconds"`
+	TotalActiveTimeDisplay      string            `json:"total_active_time_display"`
+	EstimatedCostUSD            float64           `json:"estimated_cost_usd,omitempty"`
+	EstimatedCostDisplay        string            `json:"estimated_cost_display,omitempty"`
+	TopTools                    []claug.ToolCount `json:"top_tools"`
+	FirstSessionDate            string            `json:"first_session_date"`
+	FirstSessionDateDisplay     string            `json:"first_session_date_display"`
+	LastSessionDate             string            `json:"last_session_date"`
+	LastSessionDateDisplay      string            `json:"last_session_date_display"`
+}
+
+// projectAgg accumulates one project's usage, tool counts and date range.
+type projectAgg struct {
+	claug.Usage
+	name        string
+	tools       map[string]int
+	first, last int64
+}
+
+type projectBuilder struct {
+	loc      *time.Location
+	projects map[string]*projectAgg
+}
+
+func newProjectBuilder(loc *time.Location) *projectBuilder {
+	return &projectBuilder{loc: loc, projects: make(map[string]*projectAgg)}
+}
+
+// add counts s under project, merging its tool counts when showTools is set.
+func (b *projectBuilder) add(project string, s claug.SessionStats, c *sessionCost, showTools bool) {
+	p := b.projects[project]
+	if p == nil {
+		p = &projectAgg{name: project, tools: make(map[string]int)}
+		b.projects[project] = p
+	}
+	addSession(&p.Usage, s, c)
+	if showTools {
+		for name, count := range s.ToolCounts {
+			p.tools[name] += count
+		}
+	}
+	if s.CreatedAt != 0 {
+		if p.first == 0 || s.CreatedAt < p.first {
+			p.first = s.CreatedAt
+		}
+		if s.CreatedAt > p.last {
+			p.last = s.CreatedAt
+		}
+	}
+}
+
+// export returns every project ordered by sortKey, ties broken by name.
+func (b *projectBuilder) export(sortKey string) []projectExport {
+	aggs := make([]*projectAgg, 0, len(b.projects))
+	for _, p := range b.projects {
+		aggs = append(aggs, p)
+	}
+	less := projectSortKeys[sortKey]
+	sort.Slice(aggs, func(i, j int) bool {
+		if less(aggs[i], aggs[j]) {
+			return true
+		}
+		if less(aggs[j], aggs[i]) {
+			return false
+		}
+		return aggs[i].name < aggs[j].name
+	})
+
+	rows := make([]projectExport, 0, len(aggs))
+	for _, p := range aggs {
+		row := projectExport{
+			Project:                     p.name,
+			SessionCount:                p.Sessions,
+			TotalTokens:                 p.Total,
+			TotalTokensDisplay:          claug.FormatTokens(p.Total),
+			TotalInputTokens:            p.Input,
+			TotalCacheReadInputTokens:   p.CacheRead,
+			TotalCacheReadTokensDisplay: claug.FormatTokens(p.CacheRead),
+			TotalOutputTokens:           p.Output,
+			TotalToolCalls:              p.ToolCalls,
+			TotalActiveTimeSeconds:      p.ActiveTime,
+			TotalActiveTimeDisplay:      claug.FormatTime(p.ActiveTime),
+			EstimatedCostUSD:            claug.RoundCost(p.Cost),
+			EstimatedCostDisplay:        p.CostDisplay(),
+			TopTools:                    claug.TopTools([]map[string]int{p.tools}, 3),
+		}
+		if p.first != 0 {
+			row.FirstSessionDate = time.Unix(p.first, 0).In(b.loc).Format(time.RFC3339)
+			row.FirstSessionDateDisplay = claug.FormatDate(row.FirstSessionDate)
+			row.LastSessionDate = time.Unix(p.last, 0).In(b.loc).Format(time.RFC3339)
+			row.LastSessionDateDisplay = claug.FormatDate(row.LastSessionDate)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/howiewang/personal-blog/scripts/claug"
+)
+
+// rollupsExport holds time-bucketed activity. Each series is contiguous from
+// the first to the last active bucket, with empty buckets zero-filled, so the
+// site can chart it without gap handling.
+type rollupsExport struct {
+	TimeZone string         `json:"time_zone"`
+	Daily    []rollupBucket `json:"daily"`
+	Weekly   []rollupBucket `json:"weekly"`
+	Monthly  []rollupBucket `json:"monthly"`
+}
+
+type rollupBucket struct {
+	// Period is "2006-01-02" for days, "2006-W01" (ISO week) for weeks and
+	// "2006-01" for months.
+	Period                    string  `json:"period"`
+	Start                     string  `json:"start"`
+	SessionCount              int     `json:"session_count"`
+	TotalTokens               int64   `json:"total_tokens"`
+	TotalInputTokens          int64   `json:"total_input_tokens"`
+	TotalCacheReadInputTokens int64   `json:"total_cache_read_input_tokens"`
+	TotalOutputTokens         int64   `json:"total_output_tokens"`
+	TotalToolCalls            int     `json:"total_tool_calls"`
+	TotalActiveTimeSeconds    int     `json:"total_active_time_seconds"`
+	EstimatedCostUSD          float64 `json:"estimated_cost_usd,omitempty"`
+}
+
+// granularity maps a time to the start of its bucket and steps to the next.
+type granularity struct {
+	start func(time.Time) time.Time
+	next  func(time.Time) time.Time
+	label func(time.Time) string
+}
+
+var (
+	daily = granularity{
+		start: func(t time.Time) time.Time {
+			return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+		},
+		next:  func(t time.Time) time.Time { return t.AddDate(0, 0, 1) },
+		label: func(t time.Time) string { return t.Format("2006-01-02") },
+	}
+	weekly = granularity{
+		start: func(t time.Time) time.Time {
+			d := daily.start(t)
+			// ISO weeks start on Monday.
+			offset := (int(d.Weekday()) + 6) % 7
+			return d.AddDate(0, 0, -offset)
+		},
+		next: func(t time.Time) time.Time { return t.AddDate(0, 0, 7) },
+		label: func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		},
+	}
+	monthly = granularity{
+		start: func(t time.Time) time.Time {
+			return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+		},
+		next:  func(t time.Time) time.Time { return t.AddDate(0, 1, 0) },
+		label: func(t time.Time) string { return t.Format("2006-01") },
+	}
+)
+
+// rollupBuilder accumulates sessions into daily, weekly and monthly buckets
+// in a fixed location.
+type rollupBuilder struct {
+	loc     *time.Location
+	daily   map[time.Time]*claug.Usage
+	weekly  map[time.Time]*claug.Usage
+	monthly map[time.Time]*claug.Usage
+}
+
+func newRollupBuilder(loc *time.Location) *rollupBuilder {
+	return &rollupBuilder{
+		loc:     loc,
+		daily:   make(map[time.Time]*claug.Usage),
+		weekly:  make(map[time.Time]*claug.Usage),
+		monthly: make(map[time.Time]*claug.Usage),
+	}
+}
+
+// add counts a session in the buckets containing its creation time. Sessions
+// without a creation time are skipped.
+func (b *rollupBuilder) add(s claug.SessionStats, c *sessionCost) {
+	if s.CreatedAt == 0 {
+		return
+	}
+	t := time.Unix(s.CreatedAt, 0).In(b.loc)
+	addToBucket(b.daily, daily.start(t), s, c)
+	addToBucket(b.weekly, weekly.start(t), s, c)
+	addToBucket(b.monthly, monthly.start(t), s, c)
+}
+
+func addToBucket(buckets map[time.Time]*claug.Usage, key time.Time, s claug.SessionStats, c *sessionCost) {
+	if buckets[key] == nil {
+		buckets[key] = &claug.Usage{}
+	}
+	addSession(buckets[key], s, c)
+}
+
+func (b *rollupBuilder) export() rollupsExport {
+	return rollupsExport{
+		TimeZone: b.loc.String(),
+		Daily:    series(b.daily, daily),
+		Weekly:   series(b.weekly, weekly),
+		Monthly:  series(b.monthly, monthly),
+	}
+}
+
+// series returns the buckets oldest first, zero-filling gaps.
+func series(buckets map[time.Time]*claug.Usage, g granularity) []rollupBucket {
+	if len(buckets) == 0 {
+		return []rollupBucket{}
+	}
+	keys := make([]time.Time, 0, len(buckets))
+	for k := range buckets {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Before(keys[j]) })
+
+	var out []rollupBucket
+	last := keys[len(keys)-1]
+	// Re-derive each step's start so DST shifts don't drift the buckets.
+	for t := keys[0]; !t.After(last); t = g.start(g.next(t)) {
+		row := rollupBucket{Period: g.label(t), Start: t.Format(time.RFC3339)}
+		if u := buckets[t]; u != nil {
+			row.SessionCount = u.Sessions
+			row.TotalTokens = u.Total
+			row.TotalInputTokens = u.Input
+			row.TotalCacheReadInputTokens = u.CacheRead
+			row.TotalOutputTokens = u.Output
+			row.TotalToolCalls = u.ToolCalls
+			row.TotalActiveTimeSeconds = u.ActiveTime
+			row.EstimatedCostUSD = claug.RoundCost(u.Cost)
+		}
+		out = append(out, row)
+	}
+	return out
+}
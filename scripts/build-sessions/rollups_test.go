@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/howiewang/personal-blog/scripts/claug"
+)
+
+func TestRollupsZeroFillAndTimeZone(t *testing.T) {
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Skip("no tzdata:", err)
+	}
+	b := newRollupBuilder(chicago)
+	// 2026-02-09 03:00 UTC is still Feb 8 in Chicago.
+	b.add(claug.SessionStats{CreatedAt: time.Date(2026, 2, 9, 3, 0, 0, 0, time.UTC).Unix(), TotalTokens: 10}, nil)
+	b.add(claug.SessionStats{CreatedAt: time.Date(2026, 2, 11, 18, 0, 0, 0, time.UTC).Unix(), TotalTokens: 5}, nil)
+	b.add(claug.SessionStats{TotalTokens: 99}, nil) // no date: skipped
+
+	r := b.export()
+	if r.TimeZone != "America/Chicago" {
+		t.Errorf("TimeZone = %q", r.TimeZone)
+	}
+	var days []string
+	for _, d := range r.Daily {
+		days = append(days, d.Period)
+	}
+	if want := "2026-02-08 2026-02-09 2026-02-10 2026-02-11"; strings.Join(days, " ") != want {
+		t.Errorf("daily periods = %s, want %s", strings.Join(days, " "), want)
+	}
+	if r.Daily[0].TotalTokens != 10 || r.Daily[1].SessionCount != 0 || r.Daily[3].TotalTokens != 5 {
+		t.Errorf("daily buckets = %+v", r.Daily)
+	}
+
+	// Feb 8 2026 is a Sunday (ISO week 6); Feb 11 is in week 7.
+	if len(r.Weekly) != 2 || r.Weekly[0].Period != "2026-W06" || r.Weekly[1].Period != "2026-W07" {
+		t.Errorf("weekly = %+v", r.Weekly)
+	}
+	if r.Weekly[1].Start != "2026-02-09T00:00:00-06:00" {
+		t.Errorf("week start = %s, want Monday midnight local", r.Weekly[1].Start)
+	}
+	if len(r.Monthly) != 1 || r.Monthly[0].TotalTokens != 15 {
+		t.Errorf("monthly = %+v", r.Monthly)
+	}
+}
+
+func TestRollupsAcrossDST(t *testing.T) {
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Skip("no tzdata:", err)
+	}
+	b := newRollupBuilder(chicago)
+	b.add(claug.SessionStats{CreatedAt: time.Date(2026, 3, 7, 18, 0, 0, 0, time.UTC).Unix()}, nil)
+	b.add(claug.SessionStats{CreatedAt: time.Date(2026, 3, 10, 18, 0, 0, 0, time.UTC).Unix()}, nil)
+	if n := len(b.export().Daily); n != 4 {
+		t.Errorf("got %d days across the DST change, want 4", n)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// schemaVersion is dataExport's schema_version. Bump it, along with the
+// const in cc_sessions.schema.json, whenever a change to the export would
+// break an existing reader such as the cc-sessions shortcode.
+const schemaVersion = 1
+
+// exportSchemaJSON is the JSON Schema every export is validated against. A
+// copy is published at site/static/schemas/cc_sessions.schema.json; keep the
+// two identical (TestPublishedSchemaMatches checks).
+//
+//go:embed cc_sessions.schema.json
+var exportSchemaJSON []byte
+
+var exportSchema = mustCompileSchema("cc_sessions.schema.json", exportSchemaJSON)
+
+func mustCompileSchema(name string, data []byte) *jsonschema.Schema {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		panic(fmt.Sprintf("parsing %s: %v", name, err))
+	}
+	c := jsonschema.NewCompiler()
+	c.AssertFormat()
+	if err := c.AddResource(name, doc); err != nil {
+		panic(fmt.Sprintf("loading %s: %v", name, err))
+	}
+	return c.MustCompile(name)
+}
+
+// validateExport checks encoded export JSON against exportSchema.
+func validateExport(data []byte) error {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("parsing export: %w", err)
+	}
+	if err := exportSchema.Validate(doc); err != nil {
+		return fmt.Errorf("export does not match cc_sessions.schema.json: %w", err)
+	}
+	return nil
+}
+
+// checkSchemaVersion refuses to let an export replace one written with a
+// newer schema_version, which this build-sessions would silently downgrade.
+// A missing or unreadable file is fine to replace.
+func checkSchemaVersion(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+	var existing struct {
+		SchemaVersion int `json:"schema_version"`
+	}
+	if json.Unmarshal(data, &existing) != nil {
+		return nil
+	}
+	if existing.SchemaVersion > schemaVersion {
+		return fmt.Errorf("%s has schema_version %d, newer than this build-sessions writes (%d); update build-sessions or remove the file",
+			path, existing.SchemaVersion, schemaVersion)
+	}
+	return nil
+}
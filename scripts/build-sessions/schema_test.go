@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPublishedSchemaMatches(t *testing.T) {
+	published, err := os.ReadFile("../../site/static/schemas/cc_sessions.schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(published, exportSchemaJSON) {
+		t.Error("site/static/schemas/cc_sessions.schema.json differs from scripts/build-sessions/cc_sessions.schema.json; copy it over")
+	}
+}
+
+func TestValidateExport(t *testing.T) {
+	data, err := json.Marshal(buildExport(loadTestSessions(t), testExportOptions(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validateExport(data); err != nil {
+		t.Fatalf("valid export rejected: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		mutate func(doc map[string]any)
+	}{
+		{"unknown field", func(doc map[string]any) { session(doc)["surprise"] = true }},
+		{"missing field", func(doc map[string]any) { delete(session(doc), "total_tokens") }},
+		{"wrong type", func(doc map[string]any) { session(doc)["num_tool_calls"] = "3" }},
+		{"bad date", func(doc map[string]any) { session(doc)["date"] = "Feb 7, 2026" }},
+		{"bad period", func(doc map[string]any) {
+			doc["rollups"].(map[string]any)["weekly"].([]any)[0].(map[string]any)["period"] = "2026-02-07"
+		}},
+		{"other schema version", func(doc map[string]any) { doc["schema_version"] = schemaVersion + 1 }},
+		{"unknown model field", func(doc map[string]any) {
+			doc["totals"].(map[string]any)["by_model"].([]any)[0].(map[string]any)["top_tools"] = []any{}
+		}},
+	}
+	for _, tt := range tests {
+		var doc map[string]any
+		if err := json.Unmarshal(data, &doc); err != nil {
+			t.Fatal(err)
+		}
+		tt.mutate(doc)
+		mutated, err := json.Marshal(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := validateExport(mutated); err == nil {
+			t.Errorf("%s: validateExport accepted the export", tt.name)
+		}
+	}
+}
+
+func session(doc map[string]any) map[string]any {
+	return doc["sessions"].([]any)[0].(map[string]any)
+}
+
+func TestWriteExportRefusesNewerSchema(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "cc_sessions.json")
+	newer := []byte(`{"schema_version": 99, "sessions": []}`)
+	if err := os.WriteFile(out, newer, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	data := buildExport(loadTestSessions(t), testExportOptions(t))
+	if err := writeExport(data, out); err == nil || !strings.Contains(err.Error(), "schema_version 99") {
+		t.Errorf("writeExport over a newer export = %v, want a schema_version error", err)
+	}
+	if got, _ := os.ReadFile(out); !bytes.Equal(got, newer) {
+		t.Error("writeExport replaced the newer export")
+	}
+
+	// Older and unversioned exports are replaced.
+	if err := os.WriteFile(out, []byte(`{"sessions": []}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeExport(data, out); err != nil {
+		t.Errorf("writeExport over an unversioned export: %v", err)
+	}
+}
+
+func TestWriteExportKeepsFileWhenInvalid(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "cc_sessions.json")
+	data := buildExport(loadTestSessions(t), testExportOptions(t))
+	if err := writeExport(data, out); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := os.ReadFile(out)
+
+	data.Sessions[0].SessionID = ""
+	if err := writeExport(data, out); err == nil {
+		t.Fatal("writeExport accepted a session without an ID")
+	}
+	if after, _ := os.ReadFile(out); !bytes.Equal(before, after) {
+		t.Error("invalid export replaced the previous file")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("temp file left behind: %v", entries)
+	}
+}
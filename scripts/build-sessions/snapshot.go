@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/howiewang/personal-blog/scripts/claug"
+)
+
+// snapshotVersion is bumped whenever snapshot changes incompatibly. Version 1
+// was a flat session list without the raw pages.
+const snapshotVersion = 2
+
+// defaultSnapshotFile is where fetch writes and render reads the snapshot,
+// relative to the working directory, unless CC_STATS_SNAPSHOT is set.
+const defaultSnapshotFile = "snapshot.json"
+
+// defaultSnapshotMaxAge is how old a snapshot can be before render warns.
+const defaultSnapshotMaxAge = 7 * 24 * time.Hour
+
+// snapshot is what fetch writes and render reads: every page of
+// GET /api/sessions from each env, as the API returned it apart from redact,
+// so cc_sessions.json can be rebuilt offline and reproducibly.
+type snapshot struct {
+	Version   int           `json:"version"`
+	FetchedAt int64         `json:"fetched_at"`
+	Query     string        `json:"query"` // server-side filters the pages were fetched with
+	Envs      []snapshotEnv `json:"envs"`
+}
+
+// snapshotEnv is one env's pages, in active-env order.
+type snapshotEnv struct {
+	Env      string                   `json:"env"`
+	Endpoint string                   `json:"endpoint"`
+	Pages    []claug.SessionsResponse `json:"pages"`
+}
+
+// sessions returns the snapshot's sessions tagged with their env and merged
+// across envs the same way sync merges them.
+func (s snapshot) sessions() []claug.SessionStats {
+	perEnv := make([][]claug.SessionStats, 0, len(s.Envs))
+	for _, env := range s.Envs {
+		var sessions []claug.SessionStats
+		for _, page := range env.Pages {
+			for _, session := range page.Sessions {
+				session.Env = env.Env
+				sessions = append(sessions, session)
+			}
+		}
+		perEnv = append(perEnv, sessions)
+	}
+	return mergeEnvs(perEnv)
+}
+
+// redact drops every last prompt, which the export never publishes, and
+// whatever each session's privacy rule keeps out of the export (summaries,
+// projects and tool names), so a snapshot left lying around holds nothing the
+// site wouldn't publish. Render output is unchanged, except that --project can
+// no longer select private sessions.
+func (s *snapshot) redact() {
+	for i := range s.Envs {
+		for j := range s.Envs[i].Pages {
+			sessions := s.Envs[i].Pages[j].Sessions
+			for k := range sessions {
+				sessions[k].LastPrompt = ""
+				rule := privacyRuleFor(sessions[k].PrivacyLevel)
+				if !rule.ShowSummary {
+					sessions[k].Summary = ""
+				}
+				if !rule.ShowProject {
+					sessions[k].Project = ""
+				}
+				if !rule.ShowTools {
+					sessions[k].ToolCounts = nil
+				}
+			}
+		}
+	}
+}
+
+func (s snapshot) envNames() []string {
+	names := make([]string, len(s.Envs))
+	for i, env := range s.Envs {
+		names[i] = env.Env
+	}
+	return names
+}
+
+// staleWarning describes how stale the snapshot is at now, or returns "" when
+// it is no older than maxAge. A maxAge of zero never warns.
+func (s snapshot) staleWarning(now time.Time, maxAge time.Duration) string {
+	if maxAge <= 0 {
+		return ""
+	}
+	age := now.Sub(time.Unix(s.FetchedAt, 0))
+	if age <= maxAge {
+		return ""
+	}
+	return fmt.Sprintf("snapshot was fetched %s ago (%s), more than --max-age %s; run fetch to refresh it",
+		age.Round(time.Second), time.Unix(s.FetchedAt, 0).UTC().Format(time.RFC3339), maxAge)
+}
+
+// snapshotPath returns CC_STATS_SNAPSHOT, or defaultSnapshotFile.
+func snapshotPath() string {
+	return envOr("CC_STATS_SNAPSHOT", defaultSnapshotFile)
+}
+
+// snapshotFlags are shared by every command that reads a snapshot.
+type snapshotFlags struct {
+	path   string
+	maxAge time.Duration
+}
+
+func registerSnapshotFlags(fs *flag.FlagSet, path, usage string) *snapshotFlags {
+	s := &snapshotFlags{}
+	fs.StringVar(&s.path, "snapshot", path, usage)
+	fs.DurationVar(&s.maxAge, "max-age", envDuration("CC_STATS_SNAPSHOT_MAX_AGE", defaultSnapshotMaxAge), "warn when the snapshot is older than this; 0 never warns [CC_STATS_SNAPSHOT_MAX_AGE]")
+	return s
+}
+
+// read reads the snapshot at --snapshot and warns if it is older than --max-age.
+func (s *snapshotFlags) read() (snapshot, error) {
+	snap, err := readSnapshot(s.path)
+	if err != nil {
+		return snapshot{}, err
+	}
+	if warning := snap.staleWarning(time.Now(), s.maxAge); warning != "" {
+		log.Printf("WARNING: %s: %s", s.path, warning)
+	}
+	return snap, nil
+}
+
+// writeSnapshot writes snap to path, or stdout for "-".
+func writeSnapshot(path string, snap snapshot) error {
+	if path == "-" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(snap)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("creating snapshot directory: %w", err)
+	}
+	if err := writeJSONAtomic(path, snap, "  ", nil); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return nil
+}
+
+// readSnapshot reads a snapshot written by fetch from path, or stdin for "-".
+func readSnapshot(path string) (snapshot, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return snapshot{}, fmt.Errorf("reading snapshot: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+	var snap snapshot
+	if err := json.NewDecoder(r).Decode(&snap); err != nil {
+		return snapshot{}, fmt.Errorf("parsing snapshot %s: %w", path, err)
+	}
+	if snap.Version != snapshotVersion {
+		return snapshot{}, fmt.Errorf("snapshot %s has version %d, want %d; run fetch again", path, snap.Version, snapshotVersion)
+	}
+	log.Printf("read snapshot %s (envs %s, fetched %s)", path,
+		strings.Join(snap.envNames(), ", "), time.Unix(snap.FetchedAt, 0).UTC().Format(time.RFC3339))
+	return snap, nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/howiewang/personal-blog/scripts/claug"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	sessions := loadTestSessions(t)
+	want := snapshot{
+		Version:   snapshotVersion,
+		FetchedAt: 1771000000,
+		Query:     "from=2026-02-07T00%3A00%3A00Z",
+		Envs: []snapshotEnv{{
+			Env:      "prod",
+			Endpoint: "https://api.example",
+			Pages: []claug.SessionsResponse{
+				{Sessions: sessions[:2], Total: len(sessions), Page: 1, PerPage: 2},
+				{Sessions: sessions[2:], Total: len(sessions), Page: 2, PerPage: 2},
+			},
+		}},
+	}
+	path := filepath.Join(t.TempDir(), "snapshots", "snapshot.json")
+	if err := writeSnapshot(path, want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readSnapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readSnapshot = %+v, want %+v", got, want)
+	}
+
+	// render must produce the same export from a snapshot as sync does live.
+	opts := testExportOptions(t)
+	for i := range sessions {
+		sessions[i].Env = "prod"
+	}
+	if !reflect.DeepEqual(buildExport(got.sessions(), opts), buildExport(sessions, opts)) {
+		t.Error("export from snapshot differs from export of the fetched sessions")
+	}
+}
+
+// TestSnapshotRedact checks a redacted snapshot keeps no private text yet
+// still renders the same export.
+func TestSnapshotRedact(t *testing.T) {
+	sessions := loadTestSessions(t)
+	for i := range sessions {
+		sessions[i].Env = "prod"
+	}
+	snap := snapshot{Envs: []snapshotEnv{{
+		Env:   "prod",
+		Pages: []claug.SessionsResponse{{Sessions: append([]claug.SessionStats(nil), sessions...)}},
+	}}}
+	snap.redact()
+
+	private, full := 0, 0
+	for _, s := range snap.Envs[0].Pages[0].Sessions {
+		if s.LastPrompt != "" {
+			t.Errorf("session %s (%s) kept its last prompt", s.SessionID, s.PrivacyLevel)
+		}
+		if s.PrivacyLevel == "full" {
+			full++
+		}
+		if privacyRuleFor(s.PrivacyLevel).ShowSummary {
+			continue
+		}
+		private++
+		if s.Summary != "" || s.LastPrompt != "" || s.Project != "" || s.ToolCounts != nil {
+			t.Errorf("session %s (%s) kept private fields: %+v", s.SessionID, s.PrivacyLevel, s)
+		}
+	}
+	if private == 0 || full == 0 {
+		t.Fatalf("testdata needs full and private sessions, has %d and %d", full, private)
+	}
+
+	opts := testExportOptions(t)
+	if !reflect.DeepEqual(buildExport(snap.sessions(), opts), buildExport(sessions, opts)) {
+		t.Error("export from the redacted snapshot differs from export of the fetched sessions")
+	}
+}
+
+func TestSnapshotSessionsMergesEnvs(t *testing.T) {
+	snap := snapshot{Envs: []snapshotEnv{
+		{Env: "prod", Pages: []claug.SessionsResponse{{Sessions: []claug.SessionStats{{SessionID: "a", UpdatedAt: 1}}}}},
+		{Env: "staging", Pages: []claug.SessionsResponse{{Sessions: []claug.SessionStats{{SessionID: "a", UpdatedAt: 2}, {SessionID: "b"}}}}},
+	}}
+	got := snap.sessions()
+	if len(got) != 2 {
+		t.Fatalf("sessions = %+v, want a and b", got)
+	}
+	for _, s := range got {
+		if s.Env != "staging" {
+			t.Errorf("session %s tagged %q, want staging", s.SessionID, s.Env)
+		}
+	}
+}
+
+func TestSnapshotStaleWarning(t *testing.T) {
+	fetched := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	snap := snapshot{FetchedAt: fetched.Unix()}
+	tests := []struct {
+		now    time.Time
+		maxAge time.Duration
+		stale  bool
+	}{
+		{fetched.Add(time.Hour), 24 * time.Hour, false},
+		{fetched.Add(24 * time.Hour), 24 * time.Hour, false},
+		{fetched.Add(25 * time.Hour), 24 * time.Hour, true},
+		{fetched.Add(1000 * time.Hour), 0, false}, // disabled
+	}
+	for _, tt := range tests {
+		got := snap.staleWarning(tt.now, tt.maxAge)
+		if (got != "") != tt.stale {
+			t.Errorf("staleWarning(%s, %s) = %q, want stale=%v", tt.now.Sub(fetched), tt.maxAge, got, tt.stale)
+		}
+	}
+	if got := snap.staleWarning(fetched.Add(25*time.Hour), 24*time.Hour); !strings.Contains(got, "25h0m0s ago") {
+		t.Errorf("staleWarning = %q, want the age", got)
+	}
+}
+
+func TestReadSnapshotRejectsOtherVersions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "old.json")
+	if err := os.WriteFile(path, []byte(`{"version": 1, "sessions": []}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readSnapshot(path); err == nil || !strings.Contains(err.Error(), "version 1") {
+		t.Errorf("readSnapshot = %v, want a version error", err)
+	}
+}
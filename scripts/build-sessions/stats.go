@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/howiewang/personal-blog/scripts/claug"
+)
+
+func runStats(fs *flag.FlagSet, args []string) error {
+	sf := registerSnapshotFlags(fs, os.Getenv("CC_STATS_SNAPSHOT"), "read sessions from this snapshot file (- for stdin) instead of fetching them [CC_STATS_SNAPSHOT]")
+	ff := registerFetchFlags(fs)
+	full := registerFullFlag(fs)
+	filterOpts := registerFilterFlags(fs)
+	rf := registerRenderFlags(fs)
+	asJSON := fs.Bool("json", false, "print the totals as JSON, in the shape of the export's totals block")
+	fs.Parse(args)
+
+	filter, err := filterOpts.parse()
+	if err != nil {
+		return fmt.Errorf("invalid filter: %w", err)
+	}
+	opts, err := rf.options(filter)
+	if err != nil {
+		return err
+	}
+
+	var sessions []claug.SessionStats
+	if sf.path != "" {
+		snap, err := sf.read()
+		if err != nil {
+			return err
+		}
+		sessions = snap.sessions()
+	} else if sessions, err = fetchAll(filter, ff.retry, *full); err != nil {
+		return err
+	}
+
+	totals := buildExport(sessions, opts).Totals
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(totals)
+	}
+	return printStats(os.Stdout, totals)
+}
+
+// printStats writes totals as a short report followed by a per-model table.
+func printStats(w io.Writer, t claug.Totals) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "sessions\t%d\n", t.SessionCount)
+	fmt.Fprintf(tw, "tokens\t%s (input %s, cache read %s, output %s)\n",
+		t.TotalTokensDisplay, t.TotalInputTokensDisplay, t.TotalCacheReadTokensDisplay, t.TotalOutputTokensDisplay)
+	fmt.Fprintf(tw, "tool calls\t%d\n", t.TotalToolCalls)
+	fmt.Fprintf(tw, "active time\t%s\n", t.TotalActiveTimeDisplay)
+	if t.EstimatedCostDisplay != "" {
+		cost := t.EstimatedCostDisplay
+		if t.UnpricedSessionCount > 0 {
+			cost += fmt.Sprintf(" (%d sessions unpriced)", t.UnpricedSessionCount)
+		}
+		fmt.Fprintf(tw, "estimated cost\t%s\n", cost)
+	}
+	if len(t.TopTools) > 0 {
+		tools := make([]string, len(t.TopTools))
+		for i, tool := range t.TopTools {
+			tools[i] = fmt.Sprintf("%s %d", tool.Display, tool.Count)
+		}
+		fmt.Fprintf(tw, "top tools\t%s\n", strings.Join(tools, ", "))
+	}
+
+	if len(t.ByModel) > 0 {
+		fmt.Fprintf(tw, "\nmodel\tsessions\ttokens\ttool calls\tactive time\tcost\n")
+		for _, m := range t.ByModel {
+			fmt.Fprintf(tw, "%s\t%d\t%s\t%d\t%s\t%s\n", m.Model, m.SessionCount, m.TotalTokensDisplay,
+				m.TotalToolCalls, m.TotalActiveTimeDisplay, m.EstimatedCostDisplay)
+		}
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestPrintStats(t *testing.T) {
+	totals := buildExport(loadTestSessions(t), testExportOptions(t)).Totals
+	var b strings.Builder
+	if err := printStats(&b, totals); err != nil {
+		t.Fatal(err)
+	}
+	got := b.String()
+	for _, want := range []string{
+		"sessions        " + strconv.Itoa(totals.SessionCount) + "\n",
+		"tokens          " + totals.TotalTokensDisplay + " (input ",
+		"estimated cost  " + totals.EstimatedCostDisplay,
+		"top tools       " + totals.TopTools[0].Display,
+		"\nmodel ",
+		"\n" + totals.ByModel[0].Model + " ",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("printStats output missing %q:\n%s", want, got)
+		}
+	}
+}
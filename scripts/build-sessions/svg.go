@@ -0,0 +1,301 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/howiewang/personal-blog/scripts/claug"
+)
+
+// svgFormat writes static SVG charts for the claude-log page under --svg-dir.
+const svgFormat = "svg"
+
+// The SVG charts' file names, referenced by the cc-sessions shortcode.
+const (
+	sparklineFile = "cc-tokens.svg"
+	toolBarsFile  = "cc-tools.svg"
+	heatmapFile   = "cc-activity.svg"
+)
+
+// Colors from the timberline theme, as in
+// scripts/plots/src/timberline_plots/theme.py. The charts are served as
+// <img>, so they can't read the page's CSS variables.
+const (
+	svgText   = "#2B2B2B"
+	svgMuted  = "#6B6860"
+	svgAccent = "#2E4D37"
+	svgBorder = "#C4B892"
+	svgFont   = "ui-monospace, SFMono-Regular, Menlo, Monaco, Consolas, monospace"
+)
+
+// svgColorCycle is theme.py's COLOR_CYCLE, used for the tool bars.
+var svgColorCycle = []string{"#2E4D37", "#A0522D", "#A26200", "#D1064F", "#7021FF", "#496D00", "#75715e"}
+
+const (
+	// sparklineDays is how many of the latest daily rollups the sparkline
+	// shows.
+	sparklineDays = 90
+	// heatmapWeeks is how many weeks the activity calendar spans, ending
+	// with the week of the latest daily rollup.
+	heatmapWeeks = 53
+	// toolLabelChars is the longest tool name that fits left of the bars.
+	toolLabelChars = 22
+)
+
+// svgCharts holds the rendered documents written by --format svg.
+type svgCharts struct {
+	Sparkline []byte
+	ToolBars  []byte
+	Heatmap   []byte
+}
+
+// buildSVGCharts renders the charts from data's daily rollups and top tools,
+// so they show exactly what the stat boxes and the export do.
+func buildSVGCharts(data dataExport) svgCharts {
+	return svgCharts{
+		Sparkline: tokenSparkline(data.Rollups.Daily),
+		ToolBars:  toolBars(data.Totals.TopTools),
+		Heatmap:   activityHeatmap(data.Rollups.Daily),
+	}
+}
+
+// svgDoc builds one SVG document. Coordinates are whole pixels or one
+// decimal place, so the output is stable for golden files.
+type svgDoc struct {
+	b strings.Builder
+}
+
+func newSVG(width, height int, title string) *svgDoc {
+	d := &svgDoc{}
+	fmt.Fprintf(&d.b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" role="img" aria-labelledby="title">`+"\n", width, height, width, height)
+	fmt.Fprintf(&d.b, "<title id=\"title\">%s</title>\n", escapeSVG(title))
+	fmt.Fprintf(&d.b, "<g font-family=\"%s\" font-size=\"11\">\n", svgFont)
+	return d
+}
+
+func (d *svgDoc) add(format string, args ...any) {
+	fmt.Fprintf(&d.b, format+"\n", args...)
+}
+
+// text adds a label; anchor is "start", "middle" or "end".
+func (d *svgDoc) text(x, y float64, anchor, fill, s string) {
+	d.add(`<text x="%.1f" y="%.1f" text-anchor="%s" fill="%s">%s</text>`, x, y, anchor, fill, escapeSVG(s))
+}
+
+func (d *svgDoc) bytes() []byte {
+	d.b.WriteString("</g>\n</svg>\n")
+	return []byte(d.b.String())
+}
+
+var svgEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+func escapeSVG(s string) string {
+	return svgEscaper.Replace(s)
+}
+
+// tokenSparkline draws total tokens per day over the last sparklineDays
+// daily rollups as a filled line, marking the peak and the latest day.
+func tokenSparkline(daily []rollupBucket) []byte {
+	const (
+		width, height = 640, 120
+		left, right   = 4.0, 636.0
+		top, bottom   = 26.0, 112.0
+	)
+	if len(daily) > sparklineDays {
+		daily = daily[len(daily)-sparklineDays:]
+	}
+	d := newSVG(width, height, fmt.Sprintf("Tokens per day, last %d days", len(daily)))
+	d.text(left, 12, "start", svgMuted, "TOKENS / DAY")
+	if len(daily) == 0 {
+		d.text(width/2, (top+bottom)/2, "middle", svgMuted, "No sessions yet")
+		return d.bytes()
+	}
+
+	peak := 0
+	for i, b := range daily {
+		if b.TotalTokens > daily[peak].TotalTokens {
+			peak = i
+		}
+	}
+	scale := float64(daily[peak].TotalTokens)
+	x := func(i int) float64 {
+		if len(daily) == 1 {
+			return (left + right) / 2
+		}
+		return left + (right-left)*float64(i)/float64(len(daily)-1)
+	}
+	y := func(n int64) float64 {
+		if scale == 0 {
+			return bottom
+		}
+		return bottom - (bottom-top)*float64(n)/scale
+	}
+
+	points := make([]string, len(daily))
+	for i, b := range daily {
+		points[i] = fmt.Sprintf("%.1f,%.1f", x(i), y(b.TotalTokens))
+	}
+	line := strings.Join(points, " ")
+	d.add(`<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s"/>`, left, bottom, right, bottom, svgBorder)
+	d.add(`<polygon points="%.1f,%.1f %s %.1f,%.1f" fill="%s" fill-opacity="0.15"/>`, x(0), bottom, line, x(len(daily)-1), bottom, svgAccent)
+	d.add(`<polyline points="%s" fill="none" stroke="%s" stroke-width="1.5" stroke-linejoin="round"/>`, line, svgAccent)
+	last := len(daily) - 1
+	d.add(`<circle cx="%.1f" cy="%.1f" r="3" fill="%s"/>`, x(last), y(daily[last].TotalTokens), svgAccent)
+	d.text(right, 12, "end", svgText, fmt.Sprintf("peak %s on %s", claug.FormatTokensShort(daily[peak].TotalTokens), daily[peak].Period))
+	return d.bytes()
+}
+
+// toolBars draws the public top tools as horizontal bars, longest first, in
+// the theme's color cycle.
+func toolBars(tools []claug.ToolCount) []byte {
+	const (
+		width       = 640
+		rowHeight   = 24
+		labelRight  = 150.0
+		barLeft     = 160.0
+		barMaxWidth = 400.0
+	)
+	height := 24 + rowHeight*len(tools)
+	if len(tools) == 0 {
+		height = 24 + rowHeight
+	}
+	d := newSVG(width, height, "Most used tools")
+	d.text(4, 12, "start", svgMuted, "TOOL CALLS")
+	if len(tools) == 0 {
+		d.text(width/2, 24+rowHeight/2+4, "middle", svgMuted, "No public tool calls yet")
+		return d.bytes()
+	}
+
+	most := 0
+	for _, t := range tools {
+		most = max(most, t.Count)
+	}
+	for i, t := range tools {
+		top := float64(24 + rowHeight*i)
+		w := 0.0
+		if most > 0 {
+			w = barMaxWidth * float64(t.Count) / float64(most)
+		}
+		d.text(labelRight, top+rowHeight/2+4, "end", svgText, truncateLabel(t.Display, toolLabelChars))
+		d.add(`<rect x="%.1f" y="%.1f" width="%.1f" height="%d" rx="2" fill="%s"/>`, barLeft, top+4, w, rowHeight-8, svgColorCycle[i%len(svgColorCycle)])
+		d.text(barLeft+w+6, top+rowHeight/2+4, "start", svgMuted, fmt.Sprint(t.Count))
+	}
+	return d.bytes()
+}
+
+// truncateLabel shortens s to n runes, ending in an ellipsis.
+func truncateLabel(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
+
+// activityHeatmap draws a calendar of tokens per day, one column per week
+// (Monday first) over the last heatmapWeeks weeks. Active days are shaded in
+// four steps by quartile, so one huge day doesn't wash out the rest.
+func activityHeatmap(daily []rollupBucket) []byte {
+	const (
+		cell, step = 10, 12
+		left, top  = 30, 34
+		width      = left + heatmapWeeks*step
+		height     = top + 7*step + 2
+		dayLayout  = "2006-01-02"
+	)
+	d := newSVG(width, height, fmt.Sprintf("Tokens per day, last %d weeks", heatmapWeeks))
+	d.text(4, 12, "start", svgMuted, "ACTIVITY")
+
+	tokens := make(map[string]int64, len(daily))
+	var active []int64
+	var end time.Time
+	for _, b := range daily {
+		tokens[b.Period] = b.TotalTokens
+		if b.TotalTokens > 0 {
+			active = append(active, b.TotalTokens)
+		}
+		if t, err := time.Parse(dayLayout, b.Period); err == nil && t.After(end) {
+			end = t
+		}
+	}
+	if end.IsZero() {
+		d.text(width/2, top+7*step/2, "middle", svgMuted, "No sessions yet")
+		return d.bytes()
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i] < active[j] })
+	var quartiles []int64
+	for _, q := range []int{1, 2, 3} {
+		if len(active) > 0 {
+			quartiles = append(quartiles, active[(len(active)-1)*q/4])
+		}
+	}
+
+	for i, label := range []string{"Mon", "", "Wed", "", "Fri", "", ""} {
+		if label != "" {
+			d.text(left-4, float64(top+i*step+cell-1), "end", svgMuted, label)
+		}
+	}
+	start := end.AddDate(0, 0, -weekdayIndex(end)-7*(heatmapWeeks-1))
+	for week := range heatmapWeeks {
+		monday := start.AddDate(0, 0, 7*week)
+		if week > 0 && monday.Month() != monday.AddDate(0, 0, -7).Month() {
+			d.text(float64(left+week*step), top-8, "start", svgMuted, monday.Format("Jan"))
+		}
+		for day := range 7 {
+			date := monday.AddDate(0, 0, day)
+			if date.After(end) {
+				break
+			}
+			fill, opacity := svgBorder, 0.4
+			if n := tokens[date.Format(dayLayout)]; n > 0 {
+				level := 1
+				for _, q := range quartiles {
+					if n > q {
+						level++
+					}
+				}
+				fill, opacity = svgAccent, float64(level)/4
+			}
+			d.add(`<rect x="%d" y="%d" width="%d" height="%d" rx="2" fill="%s" fill-opacity="%.2f"/>`, left+week*step, top+day*step, cell, cell, fill, opacity)
+		}
+	}
+	return d.bytes()
+}
+
+// weekdayIndex numbers days from Monday (0) to Sunday (6).
+func weekdayIndex(t time.Time) int {
+	return (int(t.Weekday()) + 6) % 7
+}
+
+// writeSVGCharts writes each chart to dir.
+func writeSVGCharts(dir string, charts svgCharts) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("creating svg directory: %w", err)
+	}
+	for _, f := range []struct {
+		name string
+		data []byte
+	}{
+		{sparklineFile, charts.Sparkline},
+		{toolBarsFile, charts.ToolBars},
+		{heatmapFile, charts.Heatmap},
+	} {
+		path := filepath.Join(dir, f.name)
+		if err := writeFileAtomic(path, f.data); err != nil {
+			return fmt.Errorf("writing %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// svgPath returns CC_STATS_SVG_DIR, or static/img/charts under the blog root.
+func svgPath() string {
+	if dir := os.Getenv("CC_STATS_SVG_DIR"); dir != "" {
+		return dir
+	}
+	return filepath.Join(blogRoot(), "static", "img", "charts")
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/howiewang/personal-blog/scripts/claug"
+)
+
+// TestSVGChartsGolden pins the rendered charts; review the golden files in a
+// browser after running go test -update.
+func TestSVGChartsGolden(t *testing.T) {
+	sessions := loadTestSessions(t)
+	dir := t.TempDir()
+	if err := writeSVGCharts(dir, buildSVGCharts(buildExport(sessions, testExportOptions(t)))); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{sparklineFile, toolBarsFile, heatmapFile} {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkWellFormed(t, name, got)
+		checkGolden(t, filepath.Join("svg", name), got)
+	}
+}
+
+func TestSVGChartsEmpty(t *testing.T) {
+	charts := buildSVGCharts(dataExport{})
+	for name, doc := range map[string][]byte{
+		sparklineFile: charts.Sparkline,
+		toolBarsFile:  charts.ToolBars,
+		heatmapFile:   charts.Heatmap,
+	} {
+		checkWellFormed(t, name, doc)
+		if !bytes.Contains(doc, []byte(" yet</text>")) {
+			t.Errorf("%s has no empty-state message:\n%s", name, doc)
+		}
+	}
+}
+
+func TestToolBarsEscapesNames(t *testing.T) {
+	doc := toolBars([]claug.ToolCount{{Name: "mcp__a&b", Count: 2, Display: `a&b <"c">`}})
+	checkWellFormed(t, "tools", doc)
+	if !strings.Contains(string(doc), "a&amp;b &lt;&quot;c&quot;&gt;") {
+		t.Errorf("tool name not escaped:\n%s", doc)
+	}
+}
+
+func TestTruncateLabel(t *testing.T) {
+	if got := truncateLabel("Read", 4); got != "Read" {
+		t.Errorf("truncateLabel(Read, 4) = %q", got)
+	}
+	if got := truncateLabel("github: create_pull_request", 10); got != "github: c…" {
+		t.Errorf("truncateLabel = %q, want %q", got, "github: c…")
+	}
+}
+
+func TestActivityHeatmapShadesByQuartile(t *testing.T) {
+	daily := []rollupBucket{
+		{Period: "2026-03-02", TotalTokens: 1},
+		{Period: "2026-03-03", TotalTokens: 0},
+		{Period: "2026-03-04", TotalTokens: 10},
+		{Period: "2026-03-05", TotalTokens: 100},
+		{Period: "2026-03-06", TotalTokens: 1000},
+	}
+	doc := string(activityHeatmap(daily))
+	for opacity, want := range map[string]int{"0.25": 1, "0.50": 1, "0.75": 1, "1.00": 1} {
+		if got := strings.Count(doc, `fill="`+svgAccent+`" fill-opacity="`+opacity+`"`); got != want {
+			t.Errorf("%d cells at opacity %s, want %d", got, opacity, want)
+		}
+	}
+	// Every day of the 52 full weeks before 2026-03-02, the empty Tuesday,
+	// and nothing after the last rollup (a Friday).
+	if got, want := strings.Count(doc, `fill="`+svgBorder+`"`), 52*7+1; got != want {
+		t.Errorf("%d empty cells, want %d", got, want)
+	}
+}
+
+func checkWellFormed(t *testing.T, name string, doc []byte) {
+	t.Helper()
+	dec := xml.NewDecoder(bytes.NewReader(doc))
+	for {
+		_, err := dec.Token()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			t.Fatalf("%s is not well-formed XML: %v\n%s", name, err, doc)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"github.com/howiewang/personal-blog/scripts/claug"
+)
+
+// sessionCost is one session's cost estimate; priced is false when the
+// session's model is missing from the pricing table.
+type sessionCost struct {
+	usd    float64
+	priced bool
+}
+
+// addSession counts s in u, along with its cost when pricing is enabled.
+func addSession(u *claug.Usage, s claug.SessionStats, c *sessionCost) {
+	if c != nil {
+		u.AddCost(c.usd, c.priced)
+	}
+	u.AddStats(s)
+}
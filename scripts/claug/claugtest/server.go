@@ -0,0 +1,194 @@
+// Package claugtest provides an in-memory fake of the claug sessions API for
+// tests: GET /api/sessions with pagination and POST /api/sessions/heartbeat,
+// plus injectable failures.
+package claugtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/howiewang/personal-blog/scripts/claug"
+)
+
+// APIKey is the bearer token the fake accepts.
+const APIKey = "test-api-key"
+
+// Failure is a canned error response. Queued failures are served, one per
+// request and in order, before any real handling.
+type Failure struct {
+	Status     int
+	RetryAfter string // optional Retry-After header value
+	Body       string
+}
+
+// Server is a fake claug API. Its fields may be changed between requests;
+// use the methods when requests may be in flight.
+type Server struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	sessions   []claug.SessionStats
+	failures   []Failure
+	requests   []*http.Request
+	heartbeats [][]claug.SessionMetrics
+	maxBatch   int
+}
+
+// NewServer starts a fake serving sessions. Call Close when done.
+func NewServer(sessions ...claug.SessionStats) *Server {
+	s := &Server{sessions: sessions}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/sessions", s.handleList)
+	mux.HandleFunc("POST /api/sessions/heartbeat", s.handleHeartbeat)
+	s.Server = httptest.NewServer(s.wrap(mux))
+	return s
+}
+
+// Config returns a claug.Config pointing at the fake.
+func (s *Server) Config() claug.Config {
+	return claug.Config{Env: "test", APIKey: APIKey, Endpoint: s.URL}
+}
+
+// Client returns a client for the fake that never sleeps between retries.
+func (s *Server) Client(retry claug.RetryPolicy) *claug.Client {
+	c := claug.NewClient(s.Config(), retry)
+	c.Logf = func(string, ...any) {}
+	c.Sleep = func(time.Duration) {}
+	return c
+}
+
+// FailNext queues failures for the next requests.
+func (s *Server) FailNext(f ...Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, f...)
+}
+
+// SetSessions replaces the sessions the fake serves.
+func (s *Server) SetSessions(sessions ...claug.SessionStats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions = sessions
+}
+
+// SetMaxBatch makes heartbeats with more than n sessions fail with 413.
+// Zero disables the limit.
+func (s *Server) SetMaxBatch(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxBatch = n
+}
+
+// Requests returns every request received so far, including failed ones.
+func (s *Server) Requests() []*http.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*http.Request(nil), s.requests...)
+}
+
+// Heartbeats returns every accepted heartbeat batch.
+func (s *Server) Heartbeats() [][]claug.SessionMetrics {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([][]claug.SessionMetrics(nil), s.heartbeats...)
+}
+
+func (s *Server) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r.Clone(r.Context()))
+		var fail *Failure
+		if len(s.failures) > 0 {
+			fail = &s.failures[0]
+			s.failures = s.failures[1:]
+		}
+		s.mu.Unlock()
+
+		if fail != nil {
+			if fail.RetryAfter != "" {
+				w.Header().Set("Retry-After", fail.RetryAfter)
+			}
+			w.WriteHeader(fail.Status)
+			_, _ = w.Write([]byte(fail.Body))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer "+APIKey {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleList serves sessions ordered by created_at, honoring page, per_page,
+// from, to, project (an exact match on the raw value) and updated_since.
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	page, _ := strconv.Atoi(q.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	perPage, _ := strconv.Atoi(q.Get("per_page"))
+	if perPage < 1 {
+		perPage = claug.DefaultPerPage
+	}
+	from, _ := time.Parse(time.RFC3339, q.Get("from"))
+	to, _ := time.Parse(time.RFC3339, q.Get("to"))
+	project := q.Get("project")
+	updatedSince, _ := strconv.ParseInt(q.Get("updated_since"), 10, 64)
+
+	s.mu.Lock()
+	var matched []claug.SessionStats
+	for _, sess := range s.sessions {
+		if !from.IsZero() && sess.CreatedAt < from.Unix() {
+			continue
+		}
+		if !to.IsZero() && sess.CreatedAt >= to.Unix() {
+			continue
+		}
+		if project != "" && sess.Project != project {
+			continue
+		}
+		if updatedSince > 0 && sess.UpdatedAt < updatedSince {
+			continue
+		}
+		matched = append(matched, sess)
+	}
+	s.mu.Unlock()
+	sort.SliceStable(matched, func(i, j int) bool { return matched[i].CreatedAt < matched[j].CreatedAt })
+
+	start := min((page-1)*perPage, len(matched))
+	end := min(start+perPage, len(matched))
+	writeJSON(w, claug.SessionsResponse{
+		Sessions: matched[start:end],
+		Total:    len(matched),
+		Page:     page,
+		PerPage:  perPage,
+	})
+}
+
+func (s *Server) handleHeartbeat(w http.ResponseWriter, r *http.Request) {
+	var payload claug.HeartbeatPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.maxBatch > 0 && len(payload.Sessions) > s.maxBatch {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		return
+	}
+	s.heartbeats = append(s.heartbeats, payload.Sessions)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
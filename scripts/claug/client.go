@@ -0,0 +1,304 @@
+package claug
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultPerPage is the page size ListSessions asks for.
+const DefaultPerPage = 100
+
+// RetryPolicy controls how API requests are retried. Budget is shared by every
+// request a Client makes, so a badly degraded API fails the run quickly
+// instead of retrying each request MaxAttempts times.
+type RetryPolicy struct {
+	MaxAttempts int           // attempts per request, including the first
+	Budget      int           // total retries allowed across the run
+	BaseDelay   time.Duration // first backoff; doubles per attempt
+	MaxDelay    time.Duration // cap for backoff and Retry-After
+}
+
+// DefaultRetryPolicy is a sensible policy for a single sync or backfill run.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	Budget:      10,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    30 * time.Second,
+}
+
+// AuthError is returned for 401/403 responses. It is never retried.
+type AuthError struct {
+	StatusCode int
+}
+
+func (e *AuthError) Error() string {
+	return fmt.Sprintf("API returned status %d: check your API key or run 'claug login'", e.StatusCode)
+}
+
+// StatusError is returned for any other unexpected status code.
+type StatusError struct {
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	if e.Body != "" {
+		return fmt.Sprintf("API returned status %d: %s", e.StatusCode, e.Body)
+	}
+	return fmt.Sprintf("API returned status %d", e.StatusCode)
+}
+
+// decodeError marks a 200 response whose body could not be decoded, which is
+// usually a connection cut mid-body.
+type decodeError struct{ err error }
+
+func (e *decodeError) Error() string { return "decoding response: " + e.err.Error() }
+func (e *decodeError) Unwrap() error { return e.err }
+
+// Client talks to one claug environment with bearer auth and retries.
+// Once configured, a Client is safe for concurrent use; all goroutines draw
+// on the same retry budget.
+type Client struct {
+	Endpoint string
+	APIKey   string
+	HTTP     *http.Client
+	Retry    RetryPolicy
+	// Logf receives progress and retry messages. Defaults to log.Printf.
+	Logf func(format string, args ...any)
+	// Sleep waits between retries. Defaults to time.Sleep.
+	Sleep func(time.Duration)
+
+	mu          sync.Mutex
+	retriesLeft int
+}
+
+// NewClient returns a Client for cfg using retry.
+func NewClient(cfg Config, retry RetryPolicy) *Client {
+	return &Client{
+		Endpoint: cfg.Endpoint,
+		APIKey:   cfg.APIKey,
+		HTTP: &http.Client{
+			Timeout: 30 * time.Second,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse // don't follow redirects
+			},
+		},
+		Retry:       retry,
+		Logf:        log.Printf,
+		Sleep:       time.Sleep,
+		retriesLeft: retry.Budget,
+	}
+}
+
+// RetriesLeft reports how much of the retry budget remains.
+func (c *Client) RetriesLeft() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.retriesLeft
+}
+
+// takeRetry spends one retry from the budget, reporting false when it is
+// exhausted.
+func (c *Client) takeRetry() (left int, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.retriesLeft <= 0 {
+		return 0, false
+	}
+	c.retriesLeft--
+	return c.retriesLeft, true
+}
+
+// ListSessions pages through GET /api/sessions with the given query (from,
+// to, updated_since, ...) and returns every session.
+func (c *Client) ListSessions(query url.Values) ([]SessionStats, error) {
+	pages, err := c.ListSessionsPages(query)
+	if err != nil {
+		return nil, err
+	}
+	var all []SessionStats
+	for _, p := range pages {
+		all = append(all, p.Sessions...)
+	}
+	return all, nil
+}
+
+// ListSessionsPages pages through GET /api/sessions like ListSessions but
+// returns every page as received, for callers that keep the raw responses.
+func (c *Client) ListSessionsPages(query url.Values) ([]SessionsResponse, error) {
+	var pages []SessionsResponse
+	got := 0
+	for page := 1; ; page++ {
+		result, err := c.ListSessionsPage(query, page, DefaultPerPage)
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, result)
+		got += len(result.Sessions)
+
+		c.Logf("page %d: got %d sessions (total so far: %d/%d)", page, len(result.Sessions), got, result.Total)
+
+		if got >= result.Total || len(result.Sessions) == 0 {
+			return pages, nil
+		}
+	}
+}
+
+// ListSessionsPage fetches a single page of GET /api/sessions.
+func (c *Client) ListSessionsPage(query url.Values, page, perPage int) (SessionsResponse, error) {
+	u, err := url.Parse(c.Endpoint + "/api/sessions")
+	if err != nil {
+		return SessionsResponse{}, fmt.Errorf("parsing endpoint URL: %w", err)
+	}
+	q := url.Values{}
+	for k, v := range query {
+		q[k] = v
+	}
+	q.Set("page", strconv.Itoa(page))
+	q.Set("per_page", strconv.Itoa(perPage))
+	u.RawQuery = q.Encode()
+
+	var result SessionsResponse
+	err = c.do("GET", u.String(), nil, func(r io.Reader) error {
+		result = SessionsResponse{}
+		return json.NewDecoder(r).Decode(&result)
+	})
+	if err != nil {
+		return SessionsResponse{}, fmt.Errorf("fetching sessions (page %d): %w", page, err)
+	}
+	return result, nil
+}
+
+// PostHeartbeat sends one batch of sessions to POST /api/sessions/heartbeat.
+func (c *Client) PostHeartbeat(sessions []SessionMetrics) error {
+	body, err := json.Marshal(HeartbeatPayload{Sessions: sessions})
+	if err != nil {
+		return fmt.Errorf("marshaling heartbeat: %w", err)
+	}
+	return c.do("POST", c.Endpoint+"/api/sessions/heartbeat", body, nil)
+}
+
+// do performs an authenticated request. A 200/204 response body is passed to
+// decode when it is non-nil. Network errors, 429s, 5xx responses and decode
+// failures are retried with exponential backoff and jitter until the attempt
+// limit or the client's budget is exhausted; other statuses fail immediately.
+func (c *Client) do(method, rawURL string, body []byte, decode func(io.Reader) error) error {
+	for attempt := 1; ; attempt++ {
+		err := c.doOnce(method, rawURL, body, decode)
+		if err == nil {
+			return nil
+		}
+		if !Retryable(err) {
+			return err
+		}
+		if attempt >= c.Retry.MaxAttempts {
+			return fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+		left, ok := c.takeRetry()
+		if !ok {
+			return fmt.Errorf("retry budget of %d exhausted: %w", c.Retry.Budget, err)
+		}
+
+		delay := c.backoff(attempt, err)
+		c.Logf("request failed (attempt %d/%d, %d retries left): %v; retrying in %s",
+			attempt, c.Retry.MaxAttempts, left, err, delay.Round(time.Millisecond))
+		c.Sleep(delay)
+	}
+}
+
+func (c *Client) doOnce(method, rawURL string, body []byte, decode func(io.Reader) error) error {
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, rawURL, reqBody)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusNoContent:
+		if decode == nil {
+			return nil
+		}
+		if err := decode(resp.Body); err != nil {
+			return &decodeError{err}
+		}
+		return nil
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return &AuthError{StatusCode: resp.StatusCode}
+	default:
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &StatusError{
+			StatusCode: resp.StatusCode,
+			Body:       string(respBody),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+}
+
+// Retryable reports whether err is worth retrying: transport and decode
+// errors, 429s and 5xx responses.
+func Retryable(err error) bool {
+	var ae *AuthError
+	if errors.As(err, &ae) {
+		return false
+	}
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.StatusCode == http.StatusTooManyRequests || se.StatusCode >= 500
+	}
+	return true
+}
+
+// backoff returns the delay before the next attempt: the server's Retry-After
+// when it sent one, otherwise exponential backoff with jitter.
+func (c *Client) backoff(attempt int, err error) time.Duration {
+	var se *StatusError
+	if errors.As(err, &se) && se.RetryAfter > 0 {
+		return min(se.RetryAfter, c.Retry.MaxDelay)
+	}
+	ceiling := c.Retry.BaseDelay << (attempt - 1)
+	if ceiling <= 0 || ceiling > c.Retry.MaxDelay {
+		ceiling = c.Retry.MaxDelay
+	}
+	return ceiling/2 + rand.N(ceiling/2+1)
+}
+
+// parseRetryAfter understands both forms of Retry-After: delta-seconds and an
+// HTTP date. It returns 0 when the header is absent or unparseable.
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -0,0 +1,245 @@
+package claug_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/howiewang/personal-blog/scripts/claug"
+	"github.com/howiewang/personal-blog/scripts/claug/claugtest"
+)
+
+func makeSessions(n int) []claug.SessionStats {
+	sessions := make([]claug.SessionStats, n)
+	for i := range sessions {
+		sessions[i] = claug.SessionStats{
+			SessionID:   fmt.Sprintf("s%03d", i),
+			CreatedAt:   int64(1_770_000_000 + i*3600),
+			UpdatedAt:   int64(1_770_000_000 + i*3600 + 60),
+			TotalTokens: int64(1000 + i),
+		}
+	}
+	return sessions
+}
+
+func TestListSessionsPaginates(t *testing.T) {
+	srv := claugtest.NewServer(makeSessions(250)...)
+	defer srv.Close()
+
+	got, err := srv.Client(claug.DefaultRetryPolicy).ListSessions(url.Values{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 250 {
+		t.Fatalf("got %d sessions, want 250", len(got))
+	}
+	if n := len(srv.Requests()); n != 3 {
+		t.Errorf("made %d requests, want 3 pages", n)
+	}
+	for i, s := range got {
+		if want := fmt.Sprintf("s%03d", i); s.SessionID != want {
+			t.Fatalf("session %d = %s, want %s", i, s.SessionID, want)
+		}
+	}
+}
+
+func TestListSessionsPagesKeepsPages(t *testing.T) {
+	srv := claugtest.NewServer(makeSessions(250)...)
+	defer srv.Close()
+
+	pages, err := srv.Client(claug.DefaultRetryPolicy).ListSessionsPages(url.Values{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 3 {
+		t.Fatalf("got %d pages, want 3", len(pages))
+	}
+	for i, want := range []int{100, 100, 50} {
+		if p := pages[i]; p.Page != i+1 || p.Total != 250 || len(p.Sessions) != want {
+			t.Errorf("page %d: page=%d total=%d sessions=%d, want page=%d total=250 sessions=%d",
+				i, p.Page, p.Total, len(p.Sessions), i+1, want)
+		}
+	}
+}
+
+func TestListSessionsPassesQuery(t *testing.T) {
+	sessions := makeSessions(10)
+	srv := claugtest.NewServer(sessions...)
+	defer srv.Close()
+
+	q := url.Values{}
+	q.Set("updated_since", fmt.Sprint(sessions[7].UpdatedAt))
+	got, err := srv.Client(claug.DefaultRetryPolicy).ListSessions(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 {
+		t.Errorf("got %d sessions, want 3 updated since s007", len(got))
+	}
+	if q.Get("page") != "" {
+		t.Error("ListSessions modified the caller's query")
+	}
+}
+
+func TestListSessionsRetriesTransientErrors(t *testing.T) {
+	srv := claugtest.NewServer(makeSessions(150)...)
+	defer srv.Close()
+	srv.FailNext(
+		claugtest.Failure{Status: http.StatusServiceUnavailable},
+		claugtest.Failure{Status: http.StatusBadGateway},
+	)
+
+	c := srv.Client(claug.DefaultRetryPolicy)
+	got, err := c.ListSessions(url.Values{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 150 {
+		t.Errorf("got %d sessions, want 150", len(got))
+	}
+	if left := c.RetriesLeft(); left != claug.DefaultRetryPolicy.Budget-2 {
+		t.Errorf("RetriesLeft = %d, want %d", left, claug.DefaultRetryPolicy.Budget-2)
+	}
+}
+
+func TestRetryAfterIsHonored(t *testing.T) {
+	srv := claugtest.NewServer(makeSessions(1)...)
+	defer srv.Close()
+	srv.FailNext(claugtest.Failure{Status: http.StatusTooManyRequests, RetryAfter: "7"})
+
+	c := srv.Client(claug.DefaultRetryPolicy)
+	var slept []time.Duration
+	c.Sleep = func(d time.Duration) { slept = append(slept, d) }
+	if _, err := c.ListSessions(url.Values{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(slept) != 1 || slept[0] != 7*time.Second {
+		t.Errorf("slept %v, want [7s]", slept)
+	}
+}
+
+func TestBackoffIsCapped(t *testing.T) {
+	srv := claugtest.NewServer(makeSessions(1)...)
+	defer srv.Close()
+	for range 4 {
+		srv.FailNext(claugtest.Failure{Status: http.StatusInternalServerError})
+	}
+
+	retry := claug.RetryPolicy{MaxAttempts: 5, Budget: 10, BaseDelay: time.Second, MaxDelay: 3 * time.Second}
+	c := srv.Client(retry)
+	var slept []time.Duration
+	c.Sleep = func(d time.Duration) { slept = append(slept, d) }
+	if _, err := c.ListSessions(url.Values{}); err != nil {
+		t.Fatal(err)
+	}
+	for i, d := range slept {
+		if d <= 0 || d > retry.MaxDelay {
+			t.Errorf("sleep %d = %v, want within (0, %v]", i, d, retry.MaxDelay)
+		}
+	}
+}
+
+func TestAuthErrorsAreNotRetried(t *testing.T) {
+	for _, status := range []int{http.StatusUnauthorized, http.StatusForbidden} {
+		srv := claugtest.NewServer(makeSessions(1)...)
+		srv.FailNext(claugtest.Failure{Status: status})
+
+		_, err := srv.Client(claug.DefaultRetryPolicy).ListSessions(url.Values{})
+		var ae *claug.AuthError
+		if !errors.As(err, &ae) || ae.StatusCode != status {
+			t.Errorf("status %d: err = %v, want AuthError", status, err)
+		}
+		if n := len(srv.Requests()); n != 1 {
+			t.Errorf("status %d: made %d requests, want 1", status, n)
+		}
+		srv.Close()
+	}
+}
+
+func TestClientErrorsAreNotRetried(t *testing.T) {
+	srv := claugtest.NewServer(makeSessions(1)...)
+	defer srv.Close()
+	srv.FailNext(claugtest.Failure{Status: http.StatusBadRequest, Body: "bad from"})
+
+	_, err := srv.Client(claug.DefaultRetryPolicy).ListSessions(url.Values{})
+	var se *claug.StatusError
+	if !errors.As(err, &se) || se.StatusCode != http.StatusBadRequest || se.Body != "bad from" {
+		t.Errorf("err = %v, want StatusError 400", err)
+	}
+}
+
+func TestRetryBudgetIsSharedAcrossRequests(t *testing.T) {
+	srv := claugtest.NewServer(makeSessions(1)...)
+	defer srv.Close()
+
+	retry := claug.DefaultRetryPolicy
+	retry.Budget = 2
+	c := srv.Client(retry)
+	for page := 1; page <= 3; page++ {
+		srv.FailNext(claugtest.Failure{Status: http.StatusServiceUnavailable})
+		_, err := c.ListSessionsPage(url.Values{}, page, 10)
+		if page <= 2 && err != nil {
+			t.Fatalf("page %d: %v", page, err)
+		}
+		if page == 3 && err == nil {
+			t.Fatal("page 3 succeeded, want retry budget exhausted")
+		}
+	}
+	if c.RetriesLeft() != 0 {
+		t.Errorf("RetriesLeft = %d, want 0", c.RetriesLeft())
+	}
+}
+
+func TestMaxAttempts(t *testing.T) {
+	srv := claugtest.NewServer(makeSessions(1)...)
+	defer srv.Close()
+	for range 10 {
+		srv.FailNext(claugtest.Failure{Status: http.StatusServiceUnavailable})
+	}
+
+	retry := claug.DefaultRetryPolicy
+	retry.MaxAttempts = 3
+	if _, err := srv.Client(retry).ListSessions(url.Values{}); err == nil {
+		t.Fatal("ListSessions succeeded, want failure")
+	}
+	if n := len(srv.Requests()); n != 3 {
+		t.Errorf("made %d requests, want 3", n)
+	}
+}
+
+func TestPostHeartbeat(t *testing.T) {
+	srv := claugtest.NewServer()
+	defer srv.Close()
+	// A 503 is retried; the batch still arrives exactly once.
+	srv.FailNext(claugtest.Failure{Status: http.StatusServiceUnavailable})
+
+	metrics := make([]claug.SessionMetrics, 3)
+	for i := range metrics {
+		metrics[i] = claug.SessionMetrics{SessionID: fmt.Sprintf("s%02d", i)}
+	}
+	if err := srv.Client(claug.DefaultRetryPolicy).PostHeartbeat(metrics); err != nil {
+		t.Fatal(err)
+	}
+	batches := srv.Heartbeats()
+	if len(batches) != 1 || len(batches[0]) != 3 || batches[0][2].SessionID != "s02" {
+		t.Errorf("server got %v, want one batch of s00-s02", batches)
+	}
+}
+
+func TestPostHeartbeatAuthError(t *testing.T) {
+	srv := claugtest.NewServer()
+	defer srv.Close()
+	srv.FailNext(claugtest.Failure{Status: http.StatusUnauthorized})
+
+	err := srv.Client(claug.DefaultRetryPolicy).PostHeartbeat(make([]claug.SessionMetrics, 3))
+	var ae *claug.AuthError
+	if !errors.As(err, &ae) {
+		t.Errorf("PostHeartbeat = %v, want AuthError", err)
+	}
+	if n := len(srv.Requests()); n != 1 {
+		t.Errorf("made %d requests, want 1", n)
+	}
+}
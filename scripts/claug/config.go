@@ -0,0 +1,154 @@
+// Package claug is a small client for the claug.ai sessions API shared by the
+// blog's build-sessions and backfill-sessions scripts. It loads the same
+// ~/.config/claug files as the claug CLI, talks to /api/sessions with retries,
+// and formats numbers the way cc-live and the claude-log page do.
+package claug
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultEndpoint is used when config.yaml has no endpoint for the env.
+const DefaultEndpoint = "https://api.claug.ai"
+
+// DefaultEnv is used when CLAUG_ENV is unset.
+const DefaultEnv = "prod"
+
+// AuthFile represents the top-level structure of ~/.config/claug/auth.json.
+type AuthFile struct {
+	Version     string                      `json:"version"`
+	Credentials map[string]*AuthCredentials `json:"credentials"`
+}
+
+// AuthCredentials matches one entry in ~/.config/claug/auth.json.
+type AuthCredentials struct {
+	Token  string `json:"token,omitempty"`
+	APIKey string `json:"api_key"`
+}
+
+// EnvConfig matches one entry in config.yaml's envs map.
+type EnvConfig struct {
+	Endpoint string `yaml:"endpoint"`
+}
+
+// FileConfig matches ~/.config/claug/config.yaml.
+type FileConfig struct {
+	Envs   map[string]EnvConfig `yaml:"envs"`
+	Active []string             `yaml:"active"`
+}
+
+// Config is the final API key + endpoint for a single environment.
+type Config struct {
+	Env      string
+	APIKey   string
+	Endpoint string
+}
+
+// ConfigDir returns CLAUG_CONFIG_DIR, or ~/.config/claug when unset.
+func ConfigDir() (string, error) {
+	if dir := os.Getenv("CLAUG_CONFIG_DIR"); dir != "" {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("getting home dir: %w", err)
+	}
+	return filepath.Join(home, ".config", "claug"), nil
+}
+
+// Load resolves the config for CLAUG_ENV (default "prod") from ConfigDir.
+func Load() (Config, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return Config{}, err
+	}
+	env := os.Getenv("CLAUG_ENV")
+	if env == "" {
+		env = DefaultEnv
+	}
+	return LoadEnv(dir, env)
+}
+
+// ActiveEnvs lists the environments a sync should read from, in order and
+// without duplicates: CLAUG_ENV alone when it is set, else config.yaml's
+// active list, else DefaultEnv.
+func ActiveEnvs(dir string) ([]string, error) {
+	if env := os.Getenv("CLAUG_ENV"); env != "" {
+		return []string{env}, nil
+	}
+	fileCfg, err := readFileConfig(dir)
+	if err != nil {
+		return nil, err
+	}
+	var envs []string
+	seen := make(map[string]bool)
+	for _, env := range fileCfg.Active {
+		if env != "" && !seen[env] {
+			seen[env] = true
+			envs = append(envs, env)
+		}
+	}
+	if len(envs) == 0 {
+		envs = []string{DefaultEnv}
+	}
+	return envs, nil
+}
+
+// LoadEnv resolves the endpoint for env from dir/config.yaml (falling back to
+// DefaultEndpoint) and its API key from dir/auth.json.
+func LoadEnv(dir, env string) (Config, error) {
+	fileCfg, err := readFileConfig(dir)
+	if err != nil {
+		return Config{}, err
+	}
+	endpoint := DefaultEndpoint
+	if envCfg, ok := fileCfg.Envs[env]; ok && envCfg.Endpoint != "" {
+		endpoint = envCfg.Endpoint
+	}
+
+	authFile := filepath.Join(dir, "auth.json")
+	authData, err := os.ReadFile(authFile)
+	if err != nil {
+		return Config{}, fmt.Errorf("reading %s: %w\nRun 'claug login' to authenticate.", authFile, err)
+	}
+
+	var auth AuthFile
+	if err := json.Unmarshal(authData, &auth); err != nil {
+		return Config{}, fmt.Errorf("parsing %s: %w", authFile, err)
+	}
+
+	creds, ok := auth.Credentials[env]
+	if !ok || creds == nil || creds.APIKey == "" {
+		return Config{}, fmt.Errorf("no api_key found for env %q in %s. Run 'claug login' to authenticate.", env, authFile)
+	}
+
+	return Config{
+		Env:      env,
+		APIKey:   creds.APIKey,
+		Endpoint: endpoint,
+	}, nil
+}
+
+// readFileConfig parses dir/config.yaml. A missing file is not an error.
+func readFileConfig(dir string) (FileConfig, error) {
+	var cfg FileConfig
+	configFile := filepath.Join(dir, "config.yaml")
+	data, err := os.ReadFile(configFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("reading %s: %w", configFile, err)
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("parsing %s: %w", configFile, err)
+	}
+	return cfg, nil
+}
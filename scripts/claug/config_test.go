@@ -0,0 +1,64 @@
+package claug
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfigDir(t *testing.T, configYAML string) string {
+	t.Helper()
+	dir := t.TempDir()
+	auth := `{"version":"1","credentials":{"prod":{"api_key":"p"},"staging":{"api_key":"s"}}}`
+	if err := os.WriteFile(filepath.Join(dir, "auth.json"), []byte(auth), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if configYAML != "" {
+		if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(configYAML), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("CLAUG_CONFIG_DIR", dir)
+	t.Setenv("CLAUG_ENV", "")
+	return dir
+}
+
+func TestActiveEnvs(t *testing.T) {
+	dir := writeConfigDir(t, `
+envs:
+  staging:
+    endpoint: https://staging.example
+active: [prod, staging, prod]
+`)
+	envs, err := ActiveEnvs(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(envs, ",") != "prod,staging" {
+		t.Fatalf("ActiveEnvs = %v, want prod,staging", envs)
+	}
+	cfg, err := LoadEnv(dir, "staging")
+	if err != nil || cfg.Endpoint != "https://staging.example" || cfg.APIKey != "s" {
+		t.Errorf("LoadEnv(staging) = %+v, %v", cfg, err)
+	}
+
+	t.Setenv("CLAUG_ENV", "staging")
+	if envs, err := ActiveEnvs(dir); err != nil || strings.Join(envs, ",") != "staging" {
+		t.Errorf("with CLAUG_ENV: %v, %v", envs, err)
+	}
+}
+
+func TestActiveEnvsDefault(t *testing.T) {
+	dir := writeConfigDir(t, "")
+	if envs, err := ActiveEnvs(dir); err != nil || strings.Join(envs, ",") != DefaultEnv {
+		t.Errorf("ActiveEnvs without config.yaml = %v, %v", envs, err)
+	}
+}
+
+func TestLoadEnvMissingCredentials(t *testing.T) {
+	dir := writeConfigDir(t, "")
+	if _, err := LoadEnv(dir, "dev"); err == nil || !strings.Contains(err.Error(), `"dev"`) {
+		t.Errorf("err = %v, want missing api_key for dev", err)
+	}
+}
@@ -0,0 +1,87 @@
+package claug
+
+import (
+	"path"
+	"strings"
+	"time"
+)
+
+// Helpers shared by the build-sessions and backfill-sessions filters, so the
+// same flags select the same sessions in both.
+
+// NoProject is the display name of sessions reported without a project.
+const NoProject = "(none)"
+
+// StringList is a repeatable, comma-separated flag value.
+type StringList []string
+
+func (l *StringList) String() string { return strings.Join(*l, ",") }
+
+func (l *StringList) Set(v string) error {
+	for _, part := range strings.Split(v, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			*l = append(*l, part)
+		}
+	}
+	return nil
+}
+
+// ParseDate accepts an empty string, a bare date (midnight UTC) or RFC3339.
+func ParseDate(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse("2006-01-02", v); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+// InWindow reports whether a session created at createdAt (unix seconds)
+// falls in [from, to); a zero bound is open. Undated sessions (createdAt 0)
+// are outside every window, as the API's from parameter already treats them.
+func InWindow(from, to time.Time, createdAt int64) bool {
+	if from.IsZero() && to.IsZero() {
+		return true
+	}
+	if createdAt == 0 {
+		return false
+	}
+	created := time.Unix(createdAt, 0)
+	return (from.IsZero() || !created.Before(from)) && (to.IsZero() || created.Before(to))
+}
+
+// ProjectName turns whatever the client reported as the project (a bare
+// name, an absolute path, a Windows cwd, a path with a trailing slash) into a
+// stable display name: the last path element, without a .git suffix.
+func ProjectName(raw string) string {
+	p := strings.TrimSpace(raw)
+	p = strings.ReplaceAll(p, `\`, "/")
+	p = strings.TrimRight(p, "/")
+	if p == "" || p == "~" {
+		return NoProject
+	}
+	name := path.Base(p)
+	name = strings.TrimSuffix(name, ".git")
+	if name == "" || name == "." {
+		return NoProject
+	}
+	return name
+}
+
+// MatchAny reports whether v matches any of the globs. Patterns are assumed
+// valid; check them with path.Match when parsing flags.
+func MatchAny(patterns []string, v string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, v); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchProject reports whether any glob matches either the raw project or
+// its ProjectName.
+func MatchProject(patterns []string, raw string) bool {
+	return MatchAny(patterns, raw) || MatchAny(patterns, ProjectName(raw))
+}
@@ -0,0 +1,92 @@
+package claug
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStringListSet(t *testing.T) {
+	var l StringList
+	_ = l.Set("a, b,,c")
+	_ = l.Set("d")
+	if got := l.String(); got != "a,b,c,d" {
+		t.Errorf("StringList = %q, want a,b,c,d", got)
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"", time.Time{}},
+		{"2026-02-07", time.Date(2026, 2, 7, 0, 0, 0, 0, time.UTC)},
+		{"2026-02-07T10:00:00-05:00", time.Date(2026, 2, 7, 15, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := ParseDate(tt.in)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("ParseDate(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+	if _, err := ParseDate("soon"); err == nil {
+		t.Error("ParseDate(soon) succeeded, want error")
+	}
+}
+
+func TestInWindow(t *testing.T) {
+	from := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		from, to time.Time
+		created  int64
+		want     bool
+	}{
+		{"no window", time.Time{}, time.Time{}, 0, true},
+		{"inside", from, to, from.Unix(), true},
+		{"before", from, to, from.Unix() - 1, false},
+		{"to is exclusive", from, to, to.Unix(), false},
+		{"open from", time.Time{}, to, 1, true},
+		{"undated with from", from, time.Time{}, 0, false},
+		{"undated with to", time.Time{}, to, 0, false},
+	}
+	for _, tt := range tests {
+		if got := InWindow(tt.from, tt.to, tt.created); got != tt.want {
+			t.Errorf("%s: InWindow = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestProjectName(t *testing.T) {
+	for raw, want := range map[string]string{
+		"personal-blog":                   "personal-blog",
+		"/Users/howie/code/claug/":        "claug",
+		`C:\src\claug\`:                   "claug",
+		"git@github.com:x/claug.git":      "claug",
+		"/Users/howie/code/claug.git":     "claug",
+		"":                                NoProject,
+		"  ":                              NoProject,
+		"~":                               NoProject,
+		"/":                               NoProject,
+		"/Users/howie/code/personal-blog": "personal-blog",
+	} {
+		if got := ProjectName(raw); got != want {
+			t.Errorf("ProjectName(%q) = %q, want %q", raw, got, want)
+		}
+	}
+}
+
+func TestMatchProject(t *testing.T) {
+	patterns := []string{"claug", "/work/*"}
+	for raw, want := range map[string]bool{
+		"/Users/howie/code/claug.git": true, // by display name
+		"/work/api":                   true, // by raw path
+		"/Users/howie/code/other":     false,
+		"":                            false,
+	} {
+		if got := MatchProject(patterns, raw); got != want {
+			t.Errorf("MatchProject(%q) = %v, want %v", raw, got, want)
+		}
+	}
+}
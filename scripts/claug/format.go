@@ -0,0 +1,95 @@
+package claug
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formatting helpers, matching cc-live's output exactly. live-status.js
+// reimplements FormatTokensShort (as formatTokens), FormatTime and
+// CleanToolName; keep them in sync.
+
+// FormatTokensShort renders a token count as "999", "45.2k" or "1.5M".
+func FormatTokensShort(n int64) string {
+	if n >= 1_000_000 {
+		return fmt.Sprintf("%.1fM", float64(n)/1_000_000)
+	}
+	if n >= 1_000 {
+		return fmt.Sprintf("%.1fk", float64(n)/1_000)
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+// FormatTokens renders a token count with thousands separators, switching to
+// "12.3M" at ten million.
+func FormatTokens(n int64) string {
+	if n >= 10_000_000 {
+		return fmt.Sprintf("%.1fM", float64(n)/1_000_000)
+	}
+	s := strconv.FormatInt(n, 10)
+	if n < 0 {
+		return s
+	}
+	var result []byte
+	for i, c := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			result = append(result, ',')
+		}
+		result = append(result, byte(c))
+	}
+	return string(result)
+}
+
+// FormatTime renders a duration in seconds as "45s", "2m 5s" or "1h 2m".
+func FormatTime(seconds int) string {
+	if seconds < 60 {
+		return fmt.Sprintf("%ds", seconds)
+	}
+	minutes := seconds / 60
+	secs := seconds % 60
+	if minutes < 60 {
+		if secs > 0 {
+			return fmt.Sprintf("%dm %ds", minutes, secs)
+		}
+		return fmt.Sprintf("%dm", minutes)
+	}
+	hours := minutes / 60
+	mins := minutes % 60
+	if mins > 0 {
+		return fmt.Sprintf("%dh %dm", hours, mins)
+	}
+	return fmt.Sprintf("%dh", hours)
+}
+
+// FormatDate renders an RFC3339 timestamp as "Jan 2, 2006". Unparseable input
+// is truncated to its date part.
+func FormatDate(isoDate string) string {
+	if isoDate == "" {
+		return ""
+	}
+	t, err := time.Parse(time.RFC3339Nano, strings.Replace(isoDate, "Z", "+00:00", 1))
+	if err != nil {
+		t, err = time.Parse(time.RFC3339, strings.Replace(isoDate, "Z", "+00:00", 1))
+		if err != nil {
+			if len(isoDate) >= 10 {
+				return isoDate[:10]
+			}
+			return isoDate
+		}
+	}
+	return t.Format("Jan 2, 2006")
+}
+
+// CleanToolName shortens MCP tool names: "mcp__plugin_github__create_pr"
+// becomes "github: create_pr". Other names are returned unchanged.
+func CleanToolName(name string) string {
+	parts := strings.Split(name, "__")
+	if len(parts) >= 3 && parts[0] == "mcp" {
+		providerParts := strings.Split(parts[1], "_")
+		service := providerParts[len(providerParts)-1]
+		return service + ": " + strings.Join(parts[2:], "__")
+	}
+	return name
+}
@@ -0,0 +1,109 @@
+package claug
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+// loadFixtures reads one table from testdata/format_fixtures.json, which
+// live-status.test.js also runs against the JS formatters. Each row is an
+// [input, want] pair.
+func loadFixtures(t *testing.T, table string) [][2]json.RawMessage {
+	t.Helper()
+	data, err := os.ReadFile("testdata/format_fixtures.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tables map[string]json.RawMessage
+	if err := json.Unmarshal(data, &tables); err != nil {
+		t.Fatal(err)
+	}
+	var rows [][2]json.RawMessage
+	if err := json.Unmarshal(tables[table], &rows); err != nil {
+		t.Fatalf("%s: %v", table, err)
+	}
+	if len(rows) == 0 {
+		t.Fatalf("%s: no fixtures", table)
+	}
+	return rows
+}
+
+func decodeRow(t *testing.T, row [2]json.RawMessage, in, want any) {
+	t.Helper()
+	if err := json.Unmarshal(row[0], in); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(row[1], want); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFormatTokensShortFixtures(t *testing.T) {
+	for _, row := range loadFixtures(t, "format_tokens_short") {
+		var n int64
+		var want string
+		decodeRow(t, row, &n, &want)
+		if got := FormatTokensShort(n); got != want {
+			t.Errorf("FormatTokensShort(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestFormatTimeFixtures(t *testing.T) {
+	for _, row := range loadFixtures(t, "format_time") {
+		var seconds int
+		var want string
+		decodeRow(t, row, &seconds, &want)
+		if got := FormatTime(seconds); got != want {
+			t.Errorf("FormatTime(%d) = %q, want %q", seconds, got, want)
+		}
+	}
+}
+
+func TestCleanToolNameFixtures(t *testing.T) {
+	for _, row := range loadFixtures(t, "clean_tool_name") {
+		var name, want string
+		decodeRow(t, row, &name, &want)
+		if got := CleanToolName(name); got != want {
+			t.Errorf("CleanToolName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestFormatTokens(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{0, "0"},
+		{999, "999"},
+		{1000, "1,000"},
+		{1234567, "1,234,567"},
+		{9999999, "9,999,999"},
+		{10_000_000, "10.0M"},
+		{-1234, "-1234"},
+	}
+	for _, tt := range tests {
+		if got := FormatTokens(tt.n); got != tt.want {
+			t.Errorf("FormatTokens(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestFormatDate(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"2026-02-07T15:04:05Z", "Feb 7, 2026"},
+		{"2026-02-07T23:30:00-06:00", "Feb 7, 2026"},
+		{"2026-02-07 garbage", "2026-02-07"},
+		{"bad", "bad"},
+	}
+	for _, tt := range tests {
+		if got := FormatDate(tt.in); got != tt.want {
+			t.Errorf("FormatDate(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
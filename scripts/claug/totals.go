@@ -0,0 +1,238 @@
+package claug
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// UnknownModel groups sessions reported without a model.
+const UnknownModel = "unknown"
+
+// Totals is the "totals" block of the blog's cc_sessions.json. build-sessions
+// writes it and backfill-sessions prints it for a dry run, so what is about
+// to be uploaded can be diffed against what the site shows.
+type Totals struct {
+	SessionCount                int           `json:"session_count"`
+	TotalTokens                 int64         `json:"total_tokens"`
+	TotalTokensDisplay          string        `json:"total_tokens_display"`
+	TotalTokensDisplayShort     string        `json:"total_tokens_display_short"`
+	TotalInputTokens            int64         `json:"total_input_tokens"`
+	TotalInputTokensDisplay     string        `json:"total_input_tokens_display"`
+	TotalCacheReadInputTokens   int64         `json:"total_cache_read_input_tokens"`
+	TotalCacheReadTokensDisplay string        `json:"total_cache_read_tokens_display"`
+	TotalOutputTokens           int64         `json:"total_output_tokens"`
+	TotalOutputTokensDisplay    string        `json:"total_output_tokens_display"`
+	TotalToolCalls              int           `json:"total_tool_calls"`
+	TotalActiveTimeSeconds      int           `json:"total_active_time_seconds"`
+	TotalActiveTimeDisplay      string        `json:"total_active_time_display"`
+	TopTools                    []ToolCount   `json:"top_tools"`
+	EstimatedCostUSD            float64       `json:"estimated_cost_usd,omitempty"`
+	EstimatedCostDisplay        string        `json:"estimated_cost_display,omitempty"`
+	UnpricedSessionCount        int           `json:"unpriced_session_count,omitempty"`
+	ByModel                     []ModelTotals `json:"by_model"`
+}
+
+// ModelTotals is one model's row in Totals.ByModel.
+type ModelTotals struct {
+	Model                       string  `json:"model"`
+	SessionCount                int     `json:"session_count"`
+	TotalTokens                 int64   `json:"total_tokens"`
+	TotalTokensDisplay          string  `json:"total_tokens_display"`
+	TotalInputTokens            int64   `json:"total_input_tokens"`
+	TotalInputTokensDisplay     string  `json:"total_input_tokens_display"`
+	TotalCacheReadInputTokens   int64   `json:"total_cache_read_input_tokens"`
+	TotalCacheReadTokensDisplay string  `json:"total_cache_read_tokens_display"`
+	TotalOutputTokens           int64   `json:"total_output_tokens"`
+	TotalOutputTokensDisplay    string  `json:"total_output_tokens_display"`
+	TotalToolCalls              int     `json:"total_tool_calls"`
+	TotalActiveTimeSeconds      int     `json:"total_active_time_seconds"`
+	TotalActiveTimeDisplay      string  `json:"total_active_time_display"`
+	EstimatedCostUSD            float64 `json:"estimated_cost_usd,omitempty"`
+	EstimatedCostDisplay        string  `json:"estimated_cost_display,omitempty"`
+	UnpricedSessionCount        int     `json:"unpriced_session_count,omitempty"`
+}
+
+// ToolCount is one entry of a top-tools list.
+type ToolCount struct {
+	Name    string `json:"name"`
+	Count   int    `json:"count"`
+	Display string `json:"display"`
+}
+
+// Usage accumulates token, time, tool-call and cost figures over a set of
+// sessions. Priced and Unpriced count sessions with and without a cost
+// estimate, so Cost is a lower bound whenever Unpriced is non-zero.
+type Usage struct {
+	Sessions   int
+	Input      int64
+	CacheRead  int64
+	Output     int64
+	Total      int64
+	ToolCalls  int
+	ActiveTime int
+	Cost       float64
+	Priced     int
+	Unpriced   int
+}
+
+// AddStats counts a session as listed by GET /api/sessions.
+func (u *Usage) AddStats(s SessionStats) {
+	u.add(s.TotalInputTokens, s.TotalCacheReadInputTokens, s.TotalOutputTokens, s.TotalTokens, s.NumToolCalls, s.ActiveTimeSeconds)
+}
+
+// AddMetrics counts a session as sent in a heartbeat.
+func (u *Usage) AddMetrics(s SessionMetrics) {
+	u.add(s.InputTokens, s.CacheReadInputTokens, s.OutputTokens, s.TotalTokens, s.ToolCalls, s.ActiveTime)
+}
+
+func (u *Usage) add(input, cacheRead, output, total int64, toolCalls, activeTime int) {
+	u.Sessions++
+	u.Input += input
+	u.CacheRead += cacheRead
+	u.Output += output
+	u.Total += total
+	u.ToolCalls += toolCalls
+	u.ActiveTime += activeTime
+}
+
+// AddCost records one session's cost estimate in USD; priced is false when
+// there was no price for it.
+func (u *Usage) AddCost(usd float64, priced bool) {
+	if priced {
+		u.Cost += usd
+		u.Priced++
+	} else {
+		u.Unpriced++
+	}
+}
+
+// CostDisplay formats Cost, or returns "" when nothing was priced.
+func (u *Usage) CostDisplay() string {
+	if u.Priced == 0 {
+		return ""
+	}
+	return FormatCost(u.Cost)
+}
+
+// Totals returns u as a Totals block. TopTools and ByModel are left to the
+// caller.
+func (u *Usage) Totals() Totals {
+	return Totals{
+		SessionCount:                u.Sessions,
+		TotalTokens:                 u.Total,
+		TotalTokensDisplay:          FormatTokens(u.Total),
+		TotalTokensDisplayShort:     FormatTokensShort(u.Total),
+		TotalInputTokens:            u.Input,
+		TotalInputTokensDisplay:     FormatTokens(u.Input),
+		TotalCacheReadInputTokens:   u.CacheRead,
+		TotalCacheReadTokensDisplay: FormatTokens(u.CacheRead),
+		TotalOutputTokens:           u.Output,
+		TotalOutputTokensDisplay:    FormatTokens(u.Output),
+		TotalToolCalls:              u.ToolCalls,
+		TotalActiveTimeSeconds:      u.ActiveTime,
+		TotalActiveTimeDisplay:      FormatTime(u.ActiveTime),
+		EstimatedCostUSD:            RoundCost(u.Cost),
+		EstimatedCostDisplay:        u.CostDisplay(),
+		UnpricedSessionCount:        u.Unpriced,
+	}
+}
+
+// ModelUsage accumulates Usage per model, for Totals.ByModel.
+type ModelUsage map[string]*Usage
+
+// For returns the Usage of model, creating it on first use.
+func (m ModelUsage) For(model string) *Usage {
+	model = ModelName(model)
+	if m[model] == nil {
+		m[model] = &Usage{}
+	}
+	return m[model]
+}
+
+// Rows converts m into ByModel rows, heaviest first.
+func (m ModelUsage) Rows() []ModelTotals {
+	rows := make([]ModelTotals, 0, len(m))
+	for model, u := range m {
+		rows = append(rows, ModelTotals{
+			Model:                       model,
+			SessionCount:                u.Sessions,
+			TotalTokens:                 u.Total,
+			TotalTokensDisplay:          FormatTokens(u.Total),
+			TotalInputTokens:            u.Input,
+			TotalInputTokensDisplay:     FormatTokens(u.Input),
+			TotalCacheReadInputTokens:   u.CacheRead,
+			TotalCacheReadTokensDisplay: FormatTokens(u.CacheRead),
+			TotalOutputTokens:           u.Output,
+			TotalOutputTokensDisplay:    FormatTokens(u.Output),
+			TotalToolCalls:              u.ToolCalls,
+			TotalActiveTimeSeconds:      u.ActiveTime,
+			TotalActiveTimeDisplay:      FormatTime(u.ActiveTime),
+			EstimatedCostUSD:            RoundCost(u.Cost),
+			EstimatedCostDisplay:        u.CostDisplay(),
+			UnpricedSessionCount:        u.Unpriced,
+		})
+	}
+	SortModelTotals(rows)
+	return rows
+}
+
+// ModelName returns model, or UnknownModel when it is empty.
+func ModelName(model string) string {
+	if model == "" {
+		return UnknownModel
+	}
+	return model
+}
+
+// RoundCost rounds a USD amount to a hundredth of a cent for export.
+func RoundCost(usd float64) float64 {
+	return math.Round(usd*10_000) / 10_000
+}
+
+// FormatCost renders a USD amount as "$1.23".
+func FormatCost(usd float64) string {
+	return fmt.Sprintf("$%.2f", usd)
+}
+
+// SortModelTotals orders rows heaviest first, ties broken by model name.
+func SortModelTotals(rows []ModelTotals) {
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].TotalTokens != rows[j].TotalTokens {
+			return rows[i].TotalTokens > rows[j].TotalTokens
+		}
+		return rows[i].Model < rows[j].Model
+	})
+}
+
+// TopTools merges tool counts and returns the n most used, ties broken by
+// name.
+func TopTools(maps []map[string]int, n int) []ToolCount {
+	merged := make(map[string]int)
+	for _, m := range maps {
+		for name, count := range m {
+			merged[name] += count
+		}
+	}
+
+	entries := make([]ToolCount, 0, len(merged))
+	for name, count := range merged {
+		entries = append(entries, ToolCount{
+			Name:    name,
+			Count:   count,
+			Display: CleanToolName(name),
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Count != entries[j].Count {
+			return entries[i].Count > entries[j].Count
+		}
+		return entries[i].Name < entries[j].Name
+	})
+
+	if len(entries) > n {
+		entries = entries[:n]
+	}
+	return entries
+}
@@ -0,0 +1,73 @@
+package claug
+
+import "testing"
+
+func TestTopTools(t *testing.T) {
+	got := TopTools([]map[string]int{
+		{"Read": 3, "Bash": 2, "mcp__github__create_pr": 2},
+		{"Bash": 1, "Edit": 1},
+		nil,
+	}, 3)
+	want := []ToolCount{
+		{Name: "Bash", Count: 3, Display: "Bash"},
+		{Name: "Read", Count: 3, Display: "Read"},
+		{Name: "mcp__github__create_pr", Count: 2, Display: CleanToolName("mcp__github__create_pr")},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("TopTools = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("TopTools[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if got := TopTools(nil, 5); got == nil || len(got) != 0 {
+		t.Errorf("TopTools(nil) = %#v, want an empty, non-nil slice", got)
+	}
+}
+
+func TestSortModelTotals(t *testing.T) {
+	rows := []ModelTotals{{Model: "b", TotalTokens: 1}, {Model: "c", TotalTokens: 5}, {Model: "a", TotalTokens: 1}}
+	SortModelTotals(rows)
+	if rows[0].Model != "c" || rows[1].Model != "a" || rows[2].Model != "b" {
+		t.Errorf("order = %v %v %v", rows[0].Model, rows[1].Model, rows[2].Model)
+	}
+}
+
+// TestModelUsage checks both tools' session types aggregate the same way.
+func TestModelUsage(t *testing.T) {
+	fromStats, fromMetrics := ModelUsage{}, ModelUsage{}
+	for _, s := range []SessionStats{
+		{Model: "opus", TotalInputTokens: 10, TotalCacheReadInputTokens: 20, TotalOutputTokens: 5, TotalTokens: 35, NumToolCalls: 2, ActiveTimeSeconds: 60},
+		{Model: "opus", TotalTokens: 100},
+		{TotalTokens: 7},
+	} {
+		fromStats.For(s.Model).AddStats(s)
+		fromMetrics.For(s.Model).AddMetrics(SessionMetrics{
+			Model: s.Model, InputTokens: s.TotalInputTokens, CacheReadInputTokens: s.TotalCacheReadInputTokens,
+			OutputTokens: s.TotalOutputTokens, TotalTokens: s.TotalTokens, ToolCalls: s.NumToolCalls, ActiveTime: s.ActiveTimeSeconds,
+		})
+	}
+	fromStats.For("opus").AddCost(1.23456, true)
+	fromStats.For("").AddCost(0, false)
+
+	rows := fromStats.Rows()
+	if len(rows) != 2 || rows[0].Model != "opus" || rows[1].Model != UnknownModel {
+		t.Fatalf("rows = %+v", rows)
+	}
+	if r := rows[0]; r.SessionCount != 2 || r.TotalTokens != 135 || r.TotalToolCalls != 2 || r.EstimatedCostUSD != 1.2346 || r.EstimatedCostDisplay != "$1.23" {
+		t.Errorf("opus row = %+v", r)
+	}
+	if r := rows[1]; r.EstimatedCostDisplay != "" || r.UnpricedSessionCount != 1 {
+		t.Errorf("unknown row = %+v", r)
+	}
+
+	metricRows := fromMetrics.Rows()
+	for i := range metricRows {
+		want := rows[i]
+		want.EstimatedCostUSD, want.EstimatedCostDisplay, want.UnpricedSessionCount = 0, "", 0
+		if metricRows[i] != want {
+			t.Errorf("metrics row %d = %+v, want %+v", i, metricRows[i], want)
+		}
+	}
+}
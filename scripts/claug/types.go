@@ -0,0 +1,63 @@
+package claug
+
+// SessionStats matches the JSON returned by GET /api/sessions.
+type SessionStats struct {
+	ID                        string         `json:"id"`
+	SessionID                 string         `json:"session_id"`
+	Provider                  string         `json:"provider"`
+	Project                   string         `json:"project"`
+	Model                     string         `json:"model"`
+	CreatedAt                 int64          `json:"created_at"`
+	Summary                   string         `json:"summary"`
+	LastPrompt                string         `json:"last_prompt"`
+	NumUserPrompts            int            `json:"num_user_prompts"`
+	NumToolCalls              int            `json:"num_tool_calls"`
+	TotalInputTokens          int64          `json:"total_input_tokens"`
+	TotalCacheReadInputTokens int64          `json:"total_cache_read_input_tokens"`
+	TotalOutputTokens         int64          `json:"total_output_tokens"`
+	TotalTokens               int64          `json:"total_tokens"`
+	ActiveTimeSeconds         int            `json:"active_time_seconds"`
+	ProviderVersion           string         `json:"provider_version"`
+	PrivacyLevel              string         `json:"privacy_level"`
+	ToolCounts                map[string]int `json:"tool_counts"`
+	UpdatedAt                 int64          `json:"updated_at"`
+	// Env is the claug environment the session was fetched from. The API
+	// doesn't send it; clients that read several environments fill it in.
+	Env string `json:"env,omitempty"`
+}
+
+// SessionsResponse is one page of GET /api/sessions.
+type SessionsResponse struct {
+	Sessions []SessionStats `json:"sessions"`
+	Total    int            `json:"total"`
+	Page     int            `json:"page"`
+	PerPage  int            `json:"per_page"`
+}
+
+// SessionMetrics is one session in a POST /api/sessions/heartbeat payload.
+type SessionMetrics struct {
+	SessionID            string         `json:"session_id"`
+	TotalTokens          int64          `json:"total_tokens"`
+	InputTokens          int64          `json:"input_tokens"`
+	CacheReadInputTokens int64          `json:"cache_read_input_tokens"`
+	OutputTokens         int64          `json:"output_tokens"`
+	ToolCalls            int            `json:"tool_calls"`
+	ToolCounts           map[string]int `json:"tool_counts,omitempty"`
+	UserPrompts          int            `json:"user_prompts"`
+	ActiveTime           int            `json:"active_time_seconds"`
+	LastPrompt           string         `json:"last_prompt,omitempty"`
+	Project              string         `json:"project"`
+	Model                string         `json:"model"`
+	Summary              string         `json:"summary,omitempty"`
+	PrivacyLevel         string         `json:"privacy_level"`
+	// CreatedAt (unix seconds) and ProviderVersion let a backfill keep a
+	// session's original start time and client version; live heartbeats
+	// leave them unset and the server stamps the session itself.
+	CreatedAt       int64  `json:"created_at,omitempty"`
+	ProviderVersion string `json:"provider_version,omitempty"`
+}
+
+// HeartbeatPayload is the body of POST /api/sessions/heartbeat.
+type HeartbeatPayload struct {
+	Sessions []SessionMetrics `json:"sessions"`
+}
@@ -0,0 +1,70 @@
+"""Example: Claude Code session charts from build-sessions' chart datasets.
+
+Regenerate the data first with `make sync` (or, from scripts/build-sessions,
+`go run . render --format charts`), which writes the CSVs to scripts/plots/data.
+"""
+
+import csv
+from collections import defaultdict
+from pathlib import Path
+
+import numpy as np
+
+import timberline_plots as tp
+
+DATA_DIR = Path(__file__).resolve().parent.parent / "data"
+
+
+def read_rows(name: str) -> list[dict[str, str]]:
+    with open(DATA_DIR / name, newline="") as f:
+        return list(csv.DictReader(f))
+
+
+# Tokens per day
+days = read_rows("tokens_per_day.csv")
+tp.line(
+    np.arange(len(days)),
+    {
+        "Input": [int(d["input_tokens"]) for d in days],
+        "Output": [int(d["output_tokens"]) for d in days],
+    },
+    title="Tokens per Day",
+    xlabel=f"Days since {days[0]['date']}",
+    ylabel="Tokens",
+    filename="cc-tokens-per-day.svg",
+)
+
+# Session length distribution
+buckets = read_rows("session_length.csv")
+fig, ax = tp.bar(
+    np.arange(len(buckets)),
+    [int(b["sessions"]) for b in buckets],
+    title="Session Length",
+    xlabel="Active time",
+    ylabel="Sessions",
+)
+ax.set_xticks(np.arange(len(buckets)), [b["bucket"] for b in buckets])
+tp.save(fig, "cc-session-length.svg")
+
+# Tool mix over time, one stacked bar per ISO week
+mix = defaultdict(dict)
+weeks, tools = [], []
+for row in read_rows("tool_mix.csv"):
+    if row["week"] not in weeks:
+        weeks.append(row["week"])
+    if row["tool_display"] not in tools:
+        tools.append(row["tool_display"])
+    mix[row["tool_display"]][row["week"]] = float(row["share"])
+
+fig, ax = tp.figure()
+x = np.arange(len(weeks))
+bottom = np.zeros(len(weeks))
+for tool in tools:
+    share = np.array([mix[tool].get(w, 0.0) for w in weeks])
+    ax.bar(x, share, bottom=bottom, label=tool)
+    bottom += share
+ax.set_xticks(x, weeks, rotation=45, ha="right")
+ax.set_ylabel("Share of tool calls")
+ax.set_title("Tool Mix by Week")
+ax.legend()
+tp.save(fig, "cc-tool-mix.svg")
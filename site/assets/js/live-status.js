@@ -3,11 +3,19 @@ import { createConnectTransport } from "@connectrpc/connect-web";
 import { SessionService } from "./gen/sessions/v1/sessions_pb";
 
 export function formatTokens(n) {
-  if (n >= 1000000) return (n / 1000000).toFixed(1) + 'M';
-  if (n >= 1000) return (n / 1000).toFixed(1) + 'k';
+  if (n >= 1000000) return toFixed1(n, 1000000) + 'M';
+  if (n >= 1000) return toFixed1(n, 1000) + 'k';
   return String(n);
 }
 
+// Go's %.1f (used for the static export) rounds exact halves to even, while
+// toFixed rounds them up. For integer n the only exact half n / unit can hit
+// is x.25, so nudge those down to match.
+function toFixed1(n, unit) {
+  if (n % unit === unit / 4) n -= 1;
+  return (n / unit).toFixed(1);
+}
+
 export function formatTime(sec) {
   if (sec < 60) return sec + 's';
   var minutes = Math.floor(sec / 60);
@@ -22,6 +30,16 @@ export function formatTime(sec) {
   return parts.join(' ');
 }
 
+export function cleanToolName(name) {
+  var parts = name.split('__');
+  if (parts.length >= 3 && parts[0] === 'mcp') {
+    var providerParts = parts[1].split('_');
+    var service = providerParts[providerParts.length - 1];
+    return service + ': ' + parts.slice(2).join('__');
+  }
+  return name;
+}
+
 export function escapeHTML(str) {
   var div = document.createElement('div');
   div.textContent = str;
@@ -98,16 +116,6 @@ function init() {
     }
   }
 
-  function cleanToolName(name) {
-    var parts = name.split('__');
-    if (parts.length >= 3 && parts[0] === 'mcp') {
-      var providerParts = parts[1].split('_');
-      var service = providerParts[providerParts.length - 1];
-      return service + ': ' + parts.slice(2).join('__');
-    }
-    return name;
-  }
-
   function formatCount(n) {
     return String(n).replace(/\B(?=(\d{3})+(?!\d))/g, ',');
   }
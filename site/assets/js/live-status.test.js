@@ -12,7 +12,8 @@ vi.mock('./gen/sessions/v1/sessions_pb', () => ({
   SessionService: {}
 }));
 
-import { formatTokens, formatTime, escapeHTML } from './live-status.js';
+import { formatTokens, formatTime, cleanToolName, escapeHTML } from './live-status.js';
+import fixtures from '../../../scripts/claug/testdata/format_fixtures.json';
 
 beforeAll(() => {
   // Set up minimal DOM so init() runs
@@ -54,6 +55,22 @@ describe('formatTime', () => {
   });
 });
 
+// Same table as scripts/claug/format_test.go: the live page must format
+// numbers exactly like the Go-built static export.
+describe('shared Go formatting fixtures', () => {
+  it.each(fixtures.format_tokens_short)('formatTokens(%j) matches FormatTokensShort', (n, want) => {
+    expect(formatTokens(n)).toBe(want);
+  });
+
+  it.each(fixtures.format_time)('formatTime(%j) matches FormatTime', (n, want) => {
+    expect(formatTime(n)).toBe(want);
+  });
+
+  it.each(fixtures.clean_tool_name)('cleanToolName(%j) matches CleanToolName', (name, want) => {
+    expect(cleanToolName(name)).toBe(want);
+  });
+});
+
 describe('escapeHTML', () => {
   it('escapes angle brackets', () => {
     expect(escapeHTML('<script>alert("xss")</script>')).toBe(